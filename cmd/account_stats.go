@@ -6,11 +6,11 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
+	"qifutil/pkg/qif"
+
 	"github.com/spf13/cobra"
 )
 
@@ -64,17 +64,13 @@ TIPS:
 
 		fmt.Printf("Analyzing accounts in %s...\n\n", inputFile)
 
-		// Load input file
-		inputBytes, err := os.ReadFile(inputFile)
+		// Parse the input file
+		qifFile, err := qif.ParseFile(inputFile, qifOptions())
 		if err != nil {
 			fmt.Printf("Error reading file: %v\n", err)
 			return
 		}
 
-		inputContent := string(inputBytes)
-		// Standardize Line Endings
-		inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
-
 		// Process selected accounts
 		var selectedAccountList []string
 		if selectedAccounts != "" {
@@ -84,20 +80,7 @@ TIPS:
 			}
 		}
 
-		// Regex pattern to match account blocks
-		accountBlockHeaderRegex := `!Account\nN(.*?)\nT(.*?)\n\^\n!Type:(.*?)\n`
-		regex, err := regexp.Compile(accountBlockHeaderRegex)
-		if err != nil {
-			fmt.Println("Error compiling regex:", err)
-			return
-		}
-
-		// Transaction regex - only match dates
-		transactionRegex := regexp.MustCompile(`D(\d{1,2})/(\d{1,2})'(\d{2})`)
-
-		// Find all account blocks
-		accountBlocks := regex.FindAllStringSubmatch(inputContent, -1)
-		if len(accountBlocks) == 0 {
+		if len(qifFile.Accounts) == 0 {
 			fmt.Println("No accounts found in the file.")
 			return
 		}
@@ -105,9 +88,9 @@ TIPS:
 		fmt.Printf("Account Statistics from %s:\n\n", inputFile)
 
 		// Process each account
-		for _, block := range accountBlocks {
-			accountName := strings.TrimSpace(block[1]) // Name group
-			accountType := strings.TrimSpace(block[3]) // AccountType group
+		for _, account := range qifFile.Accounts {
+			accountName := account.Name
+			accountType := account.Type
 
 			// Skip if not in selected accounts
 			if len(selectedAccountList) > 0 {
@@ -123,39 +106,23 @@ TIPS:
 				}
 			}
 
-			// Find transactions in the section following this account
-			loc := regex.FindStringIndex(inputContent)
-			if loc == nil {
-				continue
-			}
-
-			nextAccountLoc := regex.FindStringIndex(inputContent[loc[1]:])
-			var accountContent string
-			if nextAccountLoc != nil {
-				accountContent = inputContent[loc[1] : loc[1]+nextAccountLoc[0]]
-			} else {
-				accountContent = inputContent[loc[1]:]
-			}
-
-			// Find all transactions
-			transactions := transactionRegex.FindAllStringSubmatch(accountContent, -1)
-
 			// Process transactions
 			stats := AccountStats{
 				Name:             accountName,
 				Type:             accountType,
-				TransactionCount: len(transactions),
+				TransactionCount: len(account.Transactions) + len(account.Investments),
 				EarliestDate:     time.Date(2099, 12, 31, 0, 0, 0, 0, time.UTC),
 				LatestDate:       time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
 			} // Process dates if we have transactions
 			if stats.TransactionCount > 0 {
-				for _, t := range transactions {
-					// Process date
-					month, _ := strconv.Atoi(t[1])
-					day, _ := strconv.Atoi(t[2])
-					year, _ := strconv.Atoi("20" + t[3])
-					date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
-
+				var dates []time.Time
+				for _, t := range account.Transactions {
+					dates = append(dates, t.Date)
+				}
+				for _, t := range account.Investments {
+					dates = append(dates, t.Date)
+				}
+				for _, date := range dates {
 					if date.Before(stats.EarliestDate) {
 						stats.EarliestDate = date
 					}
@@ -170,6 +137,7 @@ TIPS:
 				fmt.Printf("  Date Range: %s to %s\n",
 					stats.EarliestDate.Format("2006-01-02"),
 					stats.LatestDate.Format("2006-01-02"))
+				printReconciliation(account)
 				fmt.Println()
 			} else {
 				// Print statistics for accounts with no transactions
@@ -190,3 +158,41 @@ func init() {
 	// Mark required flags
 	accountStatsCmd.MarkPersistentFlagRequired("inputFile")
 }
+
+// printReconciliation prints the account's opening balance and compares the
+// sum of its transactions with the balance stated in the file. Investment
+// balances include holdings, so they aren't reconciled.
+func printReconciliation(account *qif.Account) {
+	if len(account.Transactions) == 0 {
+		return
+	}
+
+	rc := qif.NewReconciler()
+	for i := range account.Transactions {
+		rc.Add(account, &account.Transactions[i])
+	}
+	r := rc.Results()[0]
+
+	if !r.OpeningDate.IsZero() {
+		fmt.Printf("  Opening Balance: %s (%s)\n", r.OpeningBalance, r.OpeningDate.Format("2006-01-02"))
+	}
+	if !r.HasStated {
+		fmt.Printf("  Balance: %s (no stated balance in the file to reconcile with)\n", r.Computed)
+		return
+	}
+
+	asOf := ""
+	if !r.StatedDate.IsZero() {
+		asOf = " as of " + r.StatedDate.Format("2006-01-02")
+	}
+	fmt.Printf("  Stated Balance: %s%s\n", r.Stated, asOf)
+	fmt.Printf("  Sum of Transactions: %s (%d transactions)\n", r.Computed, r.Transactions)
+	if r.Unreadable > 0 {
+		fmt.Printf("  Unreadable Amounts: %d transactions left out of the sum\n", r.Unreadable)
+	}
+	if r.Balanced() {
+		fmt.Println("  Reconciled: ✓ transactions add up to the stated balance")
+	} else {
+		fmt.Printf("  Reconciled: ✗ off by %s - transactions may be missing or mis-read\n", r.Difference())
+	}
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"qifutil/test"
+)
+
+func TestAccountStatsReconciliation(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+
+	sourceFile := filepath.Join(tempDir, "reconcile.qif")
+	helper.CopyTestData("reconcile.qif", sourceFile)
+
+	selectedAccounts = ""
+	inputFile = sourceFile
+
+	output := helper.CaptureOutput(func() {
+		accountStatsCmd.Run(accountStatsCmd, []string{})
+	})
+
+	helper.AssertOutputContains(output, "Opening Balance: 1000.00 (2023-01-01)")
+	helper.AssertOutputContains(output, "Stated Balance: 1454.77 as of 2023-03-31")
+	helper.AssertOutputContains(output, "Reconciled: ✓ transactions add up to the stated balance")
+	helper.AssertOutputContains(output, "Reconciled: ✗ off by 25.00")
+	helper.AssertOutputContains(output, "Balance: -120.00 (no stated balance in the file to reconcile with)")
+}
@@ -9,10 +9,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 
+	"qifutil/pkg/qif"
+
 	"github.com/spf13/cobra"
 )
 
@@ -37,7 +38,6 @@ var accountsCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		var accountNames []string
-		var accountBlockHeaderRegex string = `(?m)^!Account[^\n]*\n^N(.*?)\n^T(.*?)\n^\^\n^!Type:(Bank|CCard)\s*\n`
 
 		// Build output file path using outputPath if provided
 		outputFilePath := accountOutputFile
@@ -54,35 +54,20 @@ var accountsCmd = &cobra.Command{
 		}
 		defer accountFile.Close()
 
-		// Load input file
-		inputBytes, err := os.ReadFile(inputFile)
+		// Parse the input file
+		qifFile, err := qif.ParseFile(inputFile, qifOptions())
 		if err != nil {
 			fmt.Println("Error reading file:", err)
-		} else {
-			fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
-		}
-		inputContent := string(inputBytes)
-
-		// Standardize Line Endings to simplify Regex
-		inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
-
-		// Gather the Accounts
-		// Compile the regex
-		regex, err := regexp.Compile(accountBlockHeaderRegex)
-		if err != nil {
-			fmt.Println("Error collecting accounts:", err)
+			return
 		}
-		accountBlocks := regex.FindAllStringSubmatchIndex(inputContent, -1)
-		if len(accountBlocks) == 0 {
+		if len(qifFile.Accounts) == 0 {
 			fmt.Println("No matches found.")
 		}
 
-		// loop over each account block and pull out account names
-		for _, accountBlock := range accountBlocks {
-			accountName := inputContent[accountBlock[2]:accountBlock[3]]
-			accountName = strings.TrimSpace(accountName)
+		// loop over each account and pull out account names
+		for _, account := range qifFile.Accounts {
 			// Remove double quotes
-			accountName = strings.ReplaceAll(accountName, "\"", "")
+			accountName := strings.ReplaceAll(account.Name, "\"", "")
 			accountNames = append(accountNames, accountName)
 		}
 
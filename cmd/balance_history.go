@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"qifutil/pkg/money"
+	"qifutil/pkg/qif"
 	"qifutil/pkg/utils"
 )
 
@@ -105,7 +105,7 @@ TIPS:
 			balanceStr = openingBalance
 		}
 
-		if _, err := strconv.ParseFloat(balanceStr, 64); err != nil {
+		if _, err := money.Parse(balanceStr); err != nil {
 			fmt.Printf("Error: Invalid balance value '%s': must be a valid number\n", balanceStr)
 			os.Exit(1)
 		}
@@ -175,127 +175,64 @@ TIPS:
 		accountName := strings.TrimSpace(selectedAccounts)
 
 		// Load and parse QIF file
-		inputBytes, err := os.ReadFile(inputFile)
+		qifFile, err := qif.ParseFile(inputFile, qifOptions())
 		if err != nil {
 			fmt.Println("Error reading file:", err)
 			os.Exit(1)
 		}
 
-		fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
-		inputContent := string(inputBytes)
-
-		// Standardize Line Endings
-		inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
-
-		// Find the account block for the selected account
-		accountBlockHeaderRegex := `(?m)^!Account[^\n]*\n^N(.*?)\n^T(.*?)\n^\^\n^!Type:(Bank|CCard)\s*\n`
-		regex, err := regexp.Compile(accountBlockHeaderRegex)
-		if err != nil {
-			fmt.Println("Error compiling regex:", err)
-			os.Exit(1)
-		}
-
-		accountBlocks := regex.FindAllStringSubmatchIndex(inputContent, -1)
-		var selectedBlockContent string
-		var foundAccount bool
-
-		for _, accountBlock := range accountBlocks {
-			currentAccountName := inputContent[accountBlock[2]:accountBlock[3]]
-			if currentAccountName == accountName {
-				foundAccount = true
-
-				// Find the end of this account block
-				restOfText := inputContent[accountBlock[1]:]
-				nextTypePattern := `(?mi)^\s*!Type:.*$`
-				nextTypeRe := regexp.MustCompile(nextTypePattern)
-				nextLoc := nextTypeRe.FindStringIndex(restOfText)
-				var endPos int
-				if nextLoc != nil {
-					endPos = accountBlock[1] + nextLoc[0]
-				} else {
-					endPos = len(inputContent)
-				}
-
-				selectedBlockContent = inputContent[accountBlock[1]:endPos]
-				break
-			}
-		}
-
-		if !foundAccount {
+		// Find the selected account
+		account := qifFile.Account(accountName)
+		if account == nil {
 			fmt.Printf("Error: Account '%s' not found in file\n", accountName)
 			os.Exit(1)
 		}
 
-		// Extract transactions for this account
-		transactionRegexString := `D(?<month>\d{1,2})\/(\s?(?<day>\d{1,2}))'(?<year>\d{2})[\r\n]+(U(?<amount1>.*?)[\r\n]+)(T(?<amount2>.*?)[\r\n]+)(C(?<cleared>.*?)[\r\n]+)((N(?<number>.*?)[\r\n]+)?)(P(?<payee>.*?)[\r\n]+)((M(?<memo>.*?)[\r\n]+)?)(L(?<category>.*?)[\r\n]+)`
-		transactionRegex, err := regexp.Compile(transactionRegexString)
-		if err != nil {
-			fmt.Println("Error compiling transaction regex:", err)
-			os.Exit(1)
-		}
-
-		transactions := transactionRegex.FindAllStringSubmatch(selectedBlockContent, -1)
-		fmt.Printf("Number of transactions found: %d\n", len(transactions))
+		fmt.Printf("Number of transactions found: %d\n", len(account.Transactions))
 
 		// Build daily balance map
-		dailyBalances := make(map[string]float64)
+		dailyBalances := make(map[string]money.Amount)
 		var dateKeys []string
 		dateKeySet := make(map[string]bool)
 
-		for _, t := range transactions {
-			if len(t) > 1 {
-				month := strings.TrimSpace(t[1])
-				day := strings.TrimSpace(t[2])
-				year := strings.TrimSpace(t[4])
-				amount := strings.TrimSpace(t[6])
-
-				// Remove commas from amount (for US-formatted numbers like 1,234.56)
-				amount = strings.ReplaceAll(amount, ",", "")
+		for _, t := range account.Transactions {
+			// Parse amount (commas in US-formatted numbers like 1,234.56 are ignored)
+			amount, err := money.Parse(t.Amount)
+			if err != nil {
+				fmt.Printf("Warning: Could not parse amount '%s' in transaction\n", t.Amount)
+				continue
+			}
 
-				// Parse amount
-				amountFloat, err := strconv.ParseFloat(amount, 64)
-				if err != nil {
-					fmt.Printf("Warning: Could not parse amount '%s' in transaction\n", amount)
-					continue
-				}
+			// Validation tracking
+			validator.RecordTransaction()
+			if amount == 0 {
+				validator.AddZeroAmount()
+			}
 
-				// Validation tracking
-				validator.RecordTransaction()
-				if amountFloat == 0.0 {
-					validator.AddZeroAmount()
-				}
+			// Format date
+			fullDate := t.Date.Format("2006-01-02")
 
-				// Format date
-				fullYear := "20" + year
-				month = "0" + month
-				fullMonth := month[len(month)-2:]
-				day = "0" + day
-				fullDay := day[len(day)-2:]
-				fullDate := fullYear + "-" + fullMonth + "-" + fullDay
-
-				// Check date filtering
-				transDate, _ := time.Parse("2006-01-02", fullDate)
-				if startDate != "" {
-					startDateTime, _ := time.Parse("2006-01-02", startDate)
-					if transDate.Before(startDateTime) {
-						continue
-					}
+			// Check date filtering
+			if startDate != "" {
+				startDateTime, _ := time.Parse("2006-01-02", startDate)
+				if t.Date.Before(startDateTime) {
+					continue
 				}
-				if endDate != "" {
-					endDateTime, _ := time.Parse("2006-01-02", endDate)
-					if transDate.After(endDateTime) {
-						continue
-					}
+			}
+			if endDate != "" {
+				endDateTime, _ := time.Parse("2006-01-02", endDate)
+				if t.Date.After(endDateTime) {
+					continue
 				}
+			}
 
-				// Accumulate daily balance
-				dailyBalances[fullDate] += amountFloat
+			// Accumulate daily balance
+			dailyBalances[fullDate] += amount
 
-				// Track unique dates in order
-				if !dateKeySet[fullDate] {
-					dateKeySet[fullDate] = true
-					dateKeys = append(dateKeys, fullDate)
-				}
+			// Track unique dates in order
+			if !dateKeySet[fullDate] {
+				dateKeySet[fullDate] = true
+				dateKeys = append(dateKeys, fullDate)
 			}
 		}
 
@@ -308,7 +245,7 @@ TIPS:
 		sortDates(dateKeys)
 
 		// Calculate running balances
-		balanceFloat, _ := strconv.ParseFloat(currentBalance+openingBalance, 64) // One will be empty string
+		balance, _ := money.Parse(currentBalance + openingBalance) // One will be empty string
 		isForwardCalculation := openingBalance != ""
 
 		balanceRecords := make([]BalanceRecord, 0)
@@ -316,27 +253,27 @@ TIPS:
 		if isForwardCalculation {
 			// Forward calculation: opening balance + daily deltas
 			for _, dateStr := range dateKeys {
-				balanceFloat += dailyBalances[dateStr]
+				balance += dailyBalances[dateStr]
 				balanceRecords = append(balanceRecords, BalanceRecord{
 					Date:    dateStr,
-					Balance: fmt.Sprintf("%.2f", balanceFloat),
+					Balance: balance.String(),
 				})
 			}
 		} else {
 			// Backward calculation: start from current balance and work backward
 			// First, sum all transactions to know total change
-			var totalChange float64
+			var totalChange money.Amount
 			for _, dailyAmount := range dailyBalances {
 				totalChange += dailyAmount
 			}
 
 			// Now calculate running balance from current balance going backward
-			currentBal := balanceFloat - totalChange
+			currentBal := balance - totalChange
 			for _, dateStr := range dateKeys {
 				currentBal += dailyBalances[dateStr]
 				balanceRecords = append(balanceRecords, BalanceRecord{
 					Date:    dateStr,
-					Balance: fmt.Sprintf("%.2f", currentBal),
+					Balance: currentBal.String(),
 				})
 			}
 		}
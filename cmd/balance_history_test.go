@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"qifutil/test"
+)
+
+func TestBalanceHistoryExactSums(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+
+	// Ten years of daily 0.10 charges: summed as float64 these drift off by a cent
+	var qifData strings.Builder
+	qifData.WriteString("!Account\nNSavings\nTBank\n^\n!Type:Bank\n")
+	day := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3650; i++ {
+		fmt.Fprintf(&qifData, "D%s\nT-0.10\nPFee\n^\n", day.Format("1/2/2006"))
+		day = day.AddDate(0, 0, 1)
+	}
+	sourceFile := filepath.Join(tempDir, "fees.qif")
+	if err := os.WriteFile(sourceFile, []byte(qifData.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	selectedAccounts = "Savings"
+	startDate = ""
+	endDate = ""
+	currentBalance = ""
+	openingBalance = "365.00"
+	maxRecordsPerFile = 0
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() { openingBalance = "" }()
+
+	helper.CaptureOutput(func() {
+		balanceHistoryCmd.Run(balanceHistoryCmd, []string{})
+	})
+
+	historyFile := filepath.Join(outputDir, "Savings_balance_history_1.csv")
+	helper.AssertFileContains(historyFile, "2010-01-01,364.90\n")
+	helper.AssertFileContains(historyFile, "2019-12-29,0.00\n")
+}
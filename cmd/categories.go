@@ -9,9 +9,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
+	"qifutil/pkg/qif"
+	"qifutil/pkg/utils"
+
 	"github.com/spf13/cobra"
 )
 
@@ -35,10 +37,6 @@ var categoriesCmd = &cobra.Command{
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		var categories []string
-		var transactionRegexString string = `D(?<month>\d{1,2})\/(\s?(?<day>\d{1,2}))'(?<year>\d{2})[\r\n]+(U(?<amount1>.*?)[\r\n]+)(T(?<amount2>.*?)[\r\n]+)(C(?<cleared>.*?)[\r\n]+)((N(?<number>.*?)[\r\n]+)?)(P(?<payee>.*?)[\r\n]+)((M(?<memo>.*?)[\r\n]+)?)(L(?<category>.*?)[\r\n]+)`
-		var catRecordRegex string = `(?m)(^N(.*)\n(^D(.*)\n)?(^T(.*)\n)?(^R(.*)\n)?(^E(.*)\n)?(^I(.*)\n)?^\^\n)`
-		var catBlockHeaderRegex string = `(?m)^!Type:Cat\n`
-		var accountBlockHeaderRegex string = `(?m)^!Account[^\n]*\n^N(.*?)\n^T(.*?)\n^\^\n^!Type:(Bank|CCard)\s*\n`
 
 		// Build output file path using outputPath if provided
 		outputFilePath := categoryOutputFile
@@ -56,120 +54,42 @@ var categoriesCmd = &cobra.Command{
 		}
 		defer categoryFile.Close()
 
-		// Load input file
-		inputBytes, err := os.ReadFile(inputFile)
+		// Parse the input file
+		qifFile, err := qif.ParseFile(inputFile, qifOptions())
 		if err != nil {
 			fmt.Println("Error reading file:", err)
-		} else {
-			fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
+			return
 		}
-		inputContent := string(inputBytes)
-
-		// Standardize Line Endings to simplify Regex
-		inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
 
-		// Find the position of the Category Block
-		catTypeRe, err := regexp.Compile(catBlockHeaderRegex)
-		if err != nil {
-			fmt.Println("Error compiling regular expression: ", err)
-		}
-		loc := catTypeRe.FindStringIndex(inputContent)
-		if loc == nil {
+		// Gather categories from the Category list
+		if len(qifFile.Categories) == 0 {
 			fmt.Printf("No Category block found.\n")
-			//return nil
-		} else {
-			// Debugging output
-			fmt.Printf("Category block found at position: %d\n", loc[1])
 		}
-
-		// Find the position of the next Type block
-		restOfText := inputContent[loc[1]:]
-		nextTypePattern := `(?mi)^\s*!Type:.*$`
-		nextTypeRe := regexp.MustCompile(nextTypePattern)
-		nextLoc := nextTypeRe.FindStringIndex(restOfText)
-		var endPos int
-		if nextLoc != nil {
-			fmt.Printf("Next type found at:%d\n", nextLoc[1])
-			// Found another Type line.
-			endPos = loc[1] + nextLoc[0]
-		} else {
-			fmt.Printf("No next type block found.\n")
-			// No other Type found
-			endPos = len(inputContent)
-		}
-
-		// Extract the text between the Type lines
-		textBetweenTypes := inputContent[loc[1]:endPos]
-
-		// Use the existing pattern to match entries
-		regex, _ := regexp.Compile(catRecordRegex)
-
-		// Find all matches in the content.
-		matches := regex.FindAllStringSubmatch(textBetweenTypes, -1)
-		fmt.Printf("%d entries extracted from the category block.\n", len(matches))
-
-		// Extract patterns to array from the Category block.
-		for _, t := range matches {
-			// Check if there is a captured group and extract the content.
-			if len(t) > 1 {
-				// Ensure there is a captured group.
-				category := strings.TrimSpace(t[2])
-				categories = append(categories, category)
-			}
+		fmt.Printf("%d entries extracted from the category block.\n", len(qifFile.Categories))
+		for _, c := range qifFile.Categories {
+			categories = append(categories, c.Name)
 		}
 
 		// Gather categories from the Accounts
-		// Compile the regex
-		regex, _ = regexp.Compile(accountBlockHeaderRegex)
-
-		accountBlocks := regex.FindAllStringSubmatchIndex(inputContent, -1)
-		if len(accountBlocks) == 0 {
+		if len(qifFile.Accounts) == 0 {
 			fmt.Println("No accounts found in input file.")
 		}
 
-		// loop over each account block and pull out categories
-		for _, accountBlock := range accountBlocks {
-			// Find the next Type Block
-			accountName := inputContent[accountBlock[2]:accountBlock[3]]
+		// loop over each account and pull out categories
+		for _, account := range qifFile.Accounts {
 			// print to console for debugging
-			fmt.Printf("Processing Account: %s\n", accountName)
-
-			restOfText := inputContent[accountBlock[1]:]
-			nextTypePattern := `(?mi)^\s*!Type:.*$`
-			nextTypeRe := regexp.MustCompile(nextTypePattern)
-			nextLoc := nextTypeRe.FindStringIndex(restOfText)
-			var endPos int
-			if nextLoc != nil {
-				endPos = accountBlock[1] + nextLoc[0]
-			} else {
-				endPos = len(inputContent)
-			}
-
-			// Extract the text between the type lines
-			textBetweenTypes := inputContent[accountBlock[1]:endPos]
-
-			// Use the existing pattern to match entries
-			regex, _ := regexp.Compile(transactionRegexString)
-
-			// Find all matches in the content.
-			transactions := regex.FindAllStringSubmatch(textBetweenTypes, -1)
-			fmt.Printf("%d categories extracted from account: %s\n\n", len(transactions), accountName)
-
-			// Loop through matches and add categories to the array
-			for _, t := range transactions {
-				// Check if there is a captured group and extract the content.
-				if len(t) > 1 {
-					var category string = ""
-					rawcategory := strings.TrimSpace(t[20])
-					category, _ = splitCategoryAndTag(rawcategory)
-
-					// If the category is not empty, add it to the list
-					if category != "" {
-						// Remove double quotes
-						category = strings.ReplaceAll(category, "\"", "")
-						// Add category to the list
-						categories = append(categories, category)
-					}
+			fmt.Printf("Processing Account: %s\n", account.Name)
+			fmt.Printf("%d categories extracted from account: %s\n\n", len(account.Transactions), account.Name)
+
+			for _, t := range account.Transactions {
+				category, _ := utils.SplitCategoryAndTag(t.Category)
+
+				// If the category is not empty, add it to the list
+				if category != "" {
+					// Remove double quotes
+					category = strings.ReplaceAll(category, "\"", "")
+					// Add category to the list
+					categories = append(categories, category)
 				}
 			}
 		}
@@ -222,19 +142,3 @@ func init() {
 	categoriesCmd.Flags().StringVarP(&categoryOutputFile, "outputFile", "o", "categories.csv", "Output file for category names")
 	categoriesCmd.Flags().StringVarP(&outputFormat, "outputFormat", "f", "CSV", "Output format (CSV, JSON, XML).")
 }
-
-func splitCategoryAndTag(originalCategoryValue string) (category string, tag string) {
-	// TODO: Migrate to use utils.SplitCategoryAndTag
-	// If the category has a tag, split it out
-	if strings.Contains(originalCategoryValue, "/") {
-		// split the category and tag into separate strings and return the category
-		category = strings.Split(originalCategoryValue, "/")[0]
-		tag = strings.Split(originalCategoryValue, "/")[1]
-	} else {
-		// catgeory is the raw category
-		category = originalCategoryValue
-		tag = ""
-	}
-
-	return category, tag
-}
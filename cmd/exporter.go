@@ -0,0 +1,236 @@
+/*
+Copyright © 2025 Chris Gelhaus <chrisgelhaus@live.com>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"qifutil/pkg/mapping"
+	"qifutil/pkg/qif"
+)
+
+// Exporter writes one account's transaction records in an output format.
+// The transactions command splits an account's records into files of at
+// most --recordsPerFile records, calling Begin when it starts a file and
+// End before closing it.
+type Exporter interface {
+	// Begin starts a file named name, writing any header to w
+	Begin(w io.Writer, name string) error
+	// WriteRecord writes a record to the current file
+	WriteRecord(record TransactionRecord) error
+	// End finishes the current file, e.g. by closing its root element
+	End() error
+	// Ext returns the extension of the format's files, e.g. ".csv"
+	Ext() string
+}
+
+// exportFormat is an output format of the transactions command
+type exportFormat struct {
+	name        string // Upper-case name given to --outputFormat
+	description string // One line shown by --outputFormat list
+
+	// wholeTransactions formats write a split transaction as one record and
+	// get the transaction, with mappings applied, in record.transaction.
+	// --splitMode is ignored.
+	wholeTransactions bool
+
+	// pairTransfers formats write both halves of a transfer as one entry.
+	// Transfers are paired before the export and only the half read first
+	// is passed to the exporter.
+	pairTransfers bool
+
+	// noImportTag formats leave out the --addTagForImport tag, e.g. files
+	// handed back to Quicken
+	noImportTag bool
+
+	// preset is the app layout of a CSV preset format, nil for other formats
+	preset *csvPreset
+
+	// new returns the Exporter for one account
+	new func(s *exportSession, account exportAccount) Exporter
+
+	// start and finish, when set, run before the first account is read and
+	// after the last file is closed
+	start  func(s *exportSession) error
+	finish func(s *exportSession) error
+}
+
+// exportAccount identifies the account an Exporter writes
+type exportAccount struct {
+	name        string       // Account name from the QIF file, used for file names
+	outputName  string       // Account name after mapping, written to each record
+	accountType string       // QIF register type, e.g. Bank or CCard
+	source      *qif.Account // Account as read, with the balance its !Account record states
+}
+
+// exportSession is one run of the transactions command, shared by the
+// exporters of every account
+type exportSession struct {
+	format          exportFormat
+	inputFile       string
+	outputPath      string
+	columns         string // --csvColumns
+	maxRecords      int    // Records per file, 0 for no limit
+	accountMapping  *mapping.Mapping
+	categoryMapping *mapping.Mapping
+	tagMapping      *mapping.Mapping
+	transfers       map[string]bool // Transfer IDs written, for pairTransfers formats
+	book            *ledgerBook     // Journal of the plain-text accounting formats
+	lists           *qifLists       // Category, class and tag lists of the QIF format
+}
+
+// firstSide reports whether a transfer is being written for the first
+// time. Its other half is already in the entry written for the first.
+func (s *exportSession) firstSide(transferID string) bool {
+	if s.transfers[transferID] {
+		return false
+	}
+	s.transfers[transferID] = true
+	return true
+}
+
+// exportFormats is the registry of output formats, by name
+var exportFormats = make(map[string]exportFormat)
+
+// registerFormat adds an output format to the registry
+func registerFormat(f exportFormat) {
+	exportFormats[strings.ToUpper(f.name)] = f
+}
+
+// lookupFormat returns the output format with the given name, ignoring case
+func lookupFormat(name string) (exportFormat, bool) {
+	f, ok := exportFormats[strings.ToUpper(strings.TrimSpace(name))]
+	return f, ok
+}
+
+// formatNames returns the names of the registered formats in order
+func formatNames() []string {
+	names := make([]string, 0, len(exportFormats))
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printFormats lists the registered formats for --outputFormat list
+func printFormats() {
+	fmt.Println("Available output formats:")
+	for _, name := range formatNames() {
+		fmt.Printf("  %-10s %s\n", name, exportFormats[name].description)
+	}
+}
+
+func init() {
+	registerFormat(exportFormat{
+		name:        "CSV",
+		description: "CSV with the columns chosen by --csvColumns",
+		new: func(s *exportSession, account exportAccount) Exporter {
+			return &csvExporter{columns: s.columns}
+		},
+	})
+	registerFormat(exportFormat{
+		name:        "JSON",
+		description: "JSON array of transaction objects",
+		new: func(s *exportSession, account exportAccount) Exporter {
+			return &jsonExporter{}
+		},
+	})
+	registerFormat(exportFormat{
+		name:        "XML",
+		description: "XML document of transaction elements",
+		new: func(s *exportSession, account exportAccount) Exporter {
+			return &xmlExporter{}
+		},
+	})
+}
+
+// csvExporter writes records as CSV rows with the chosen columns
+type csvExporter struct {
+	columns string
+	w       io.Writer
+}
+
+func (e *csvExporter) Begin(w io.Writer, name string) error {
+	e.w = w
+	return writeHeader(w, e.columns+"\n")
+}
+
+func (e *csvExporter) WriteRecord(record TransactionRecord) error {
+	return writeTransaction(e.w, buildCSVRow(record, e.columns))
+}
+
+func (e *csvExporter) End() error { return nil }
+
+func (e *csvExporter) Ext() string { return ".csv" }
+
+// jsonExporter writes records as a JSON array
+type jsonExporter struct {
+	w       io.Writer
+	records int // Records in the current file
+}
+
+func (e *jsonExporter) Begin(w io.Writer, name string) error {
+	e.w = w
+	e.records = 0
+	_, err := io.WriteString(w, "[")
+	return err
+}
+
+func (e *jsonExporter) WriteRecord(record TransactionRecord) error {
+	data, err := json.MarshalIndent(record, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	separator := ",\n  "
+	if e.records == 0 {
+		separator = "\n  "
+	}
+	e.records++
+	_, err = io.WriteString(e.w, separator+string(data))
+	return err
+}
+
+func (e *jsonExporter) End() error {
+	end := "]"
+	if e.records > 0 {
+		end = "\n]"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+func (e *jsonExporter) Ext() string { return ".json" }
+
+// xmlExporter writes records as transaction elements of a transactions document
+type xmlExporter struct {
+	encoder *xml.Encoder
+}
+
+func (e *xmlExporter) Begin(w io.Writer, name string) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e.encoder = xml.NewEncoder(w)
+	e.encoder.Indent("", "  ")
+	return e.encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: "transactions"}})
+}
+
+func (e *xmlExporter) WriteRecord(record TransactionRecord) error {
+	return e.encoder.EncodeElement(record, xml.StartElement{Name: xml.Name{Local: "transaction"}})
+}
+
+func (e *xmlExporter) End() error {
+	if err := e.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "transactions"}}); err != nil {
+		return err
+	}
+	return e.encoder.Flush()
+}
+
+func (e *xmlExporter) Ext() string { return ".xml" }
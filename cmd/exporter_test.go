@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"qifutil/test"
+)
+
+// countingExporter writes each file as "begin", one line per record and "end"
+type countingExporter struct {
+	w io.Writer
+}
+
+func (e *countingExporter) Begin(w io.Writer, name string) error {
+	e.w = w
+	_, err := fmt.Fprintf(w, "begin %s\n", name)
+	return err
+}
+
+func (e *countingExporter) WriteRecord(record TransactionRecord) error {
+	_, err := fmt.Fprintf(e.w, "%s %s\n", record.Date, record.Amount)
+	return err
+}
+
+func (e *countingExporter) End() error {
+	_, err := io.WriteString(e.w, "end\n")
+	return err
+}
+
+func (e *countingExporter) Ext() string { return ".txt" }
+
+func TestRegisteredFormat(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "splits.qif")
+	helper.CopyTestData("splits.qif", sourceFile)
+
+	finished := false
+	registerFormat(exportFormat{
+		name:        "COUNTING",
+		description: "Test format",
+		new: func(s *exportSession, account exportAccount) Exporter {
+			return &countingExporter{}
+		},
+		finish: func(s *exportSession) error {
+			finished = true
+			return nil
+		},
+	})
+	defer delete(exportFormats, "COUNTING")
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "counting"
+	maxRecordsPerFile = 1
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() { outputFormat = "CSV"; maxRecordsPerFile = 5000 }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// Every file is begun and ended, whichever format writes it
+	helper.AssertFileContains(filepath.Join(outputDir, "Checking Account_1.txt"), "begin Checking Account_1.txt\n2023-03-04 -100.00\nend\n")
+	helper.AssertFileContains(filepath.Join(outputDir, "Checking Account_2.txt"), "begin Checking Account_2.txt\n2023-03-05 -12.00\nend\n")
+	if !finished {
+		t.Error("The format's finish function was not called")
+	}
+}
+
+func TestOutputFormatList(t *testing.T) {
+	helper := test.NewHelper(t)
+
+	outputFormat = "list"
+	defer func() { outputFormat = "CSV" }()
+
+	output := helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	helper.AssertOutputContains(output, "Available output formats:")
+	for _, name := range []string{"CSV", "MONARCH", "JSON", "XML", "OFX", "OFX2", "QIF", "LEDGER", "HLEDGER", "BEANCOUNT"} {
+		helper.AssertOutputContains(output, "  "+name+" ")
+	}
+}
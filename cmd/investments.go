@@ -0,0 +1,260 @@
+/*
+Copyright © 2025 Chris Gelhaus <chrisgelhaus@live.com>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"qifutil/pkg/money"
+	"qifutil/pkg/qif"
+	"qifutil/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// Columns written for investment CSV output
+const investmentColumns = "Date,Account,Action,Security,Quantity,Price,Commission,Amount,Transfer Amount,Transfer Account,Memo"
+
+type InvestmentRecord struct {
+	Date            string `json:"date" xml:"date"`
+	Account         string `json:"account" xml:"account"`
+	Action          string `json:"action" xml:"action"`
+	Security        string `json:"security" xml:"security"`
+	Quantity        string `json:"quantity" xml:"quantity"`
+	Price           string `json:"price" xml:"price"`
+	Commission      string `json:"commission" xml:"commission"`
+	Amount          string `json:"amount" xml:"amount"`
+	TransferAmount  string `json:"transfer_amount" xml:"transfer_amount"`
+	TransferAccount string `json:"transfer_account" xml:"transfer_account"`
+	Memo            string `json:"memo" xml:"memo"`
+}
+type investmentList struct {
+	XMLName     xml.Name           `xml:"investments"`
+	Investments []InvestmentRecord `xml:"investment"`
+}
+
+// investmentsCmd represents the investments export command
+var investmentsCmd = &cobra.Command{
+	Use:   "investments",
+	Short: "Export investment account (!Type:Invst) transactions",
+	Long: `Export the registers of investment accounts to CSV, JSON or XML.
+
+Investment registers record trades rather than simple payments, so they are
+exported separately from the transactions command. Each row carries the
+action, security, quantity, price, commission and amount of one entry.
+
+EXAMPLE:
+  qifutil export investments \
+    --inputFile data.qif \
+    --outputPath ./export/ \
+    --accounts "Brokerage"
+
+CSV COLUMNS:
+  Date, Account, Action, Security, Quantity, Price, Commission, Amount,
+  Transfer Amount, Transfer Account, Memo
+
+COMMON ACTIONS:
+  Buy, Sell, Div, ReinvDiv, IntInc, ShrsIn, ShrsOut, XIn, XOut, MiscExp
+
+TIPS:
+  - File naming: {AccountName}_investments.csv (or .json/.xml)
+  - Use list-accounts --showTypes to find accounts of type Invst`,
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if inputFile == "" {
+			fmt.Println("Error: Missing required flag --inputFile")
+			os.Exit(1)
+		}
+
+		// Validate date format if provided
+		dateFormat := "2006-01-02"
+		if startDate != "" {
+			if _, err := time.Parse(dateFormat, startDate); err != nil {
+				fmt.Println("Error: Invalid start date format. Use YYYY-MM-DD")
+				os.Exit(1)
+			}
+		}
+		if endDate != "" {
+			if _, err := time.Parse(dateFormat, endDate); err != nil {
+				fmt.Println("Error: Invalid end date format. Use YYYY-MM-DD")
+				os.Exit(1)
+			}
+		}
+	},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Starting investment export...")
+
+		// Ensure we have a valid output path
+		if outputPath == "" {
+			fmt.Println("Error: No output path specified")
+			os.Exit(1)
+		}
+
+		// Create the output directory
+		outputPath = filepath.Clean(outputPath)
+		if mkdirErr := os.MkdirAll(outputPath, 0755); mkdirErr != nil {
+			fmt.Printf("Error creating output directory: %v\n", mkdirErr)
+			os.Exit(1)
+		}
+
+		// Process the selected accounts into a list
+		var selectedAccountList []string
+		if selectedAccounts != "" {
+			selectedAccountList = strings.Split(selectedAccounts, ",")
+			for i := range selectedAccountList {
+				selectedAccountList[i] = strings.TrimSpace(selectedAccountList[i])
+			}
+		}
+
+		qifFile, err := qif.ParseFile(inputFile, qifOptions())
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			os.Exit(1)
+		}
+
+		exported := 0
+		for _, account := range qifFile.Accounts {
+			if !strings.EqualFold(account.Type, "Invst") {
+				continue
+			}
+			if len(selectedAccountList) > 0 && !containsString(selectedAccountList, account.Name) {
+				continue
+			}
+
+			var records []InvestmentRecord
+			for _, t := range account.Investments {
+				if startDate != "" {
+					startDateTime, _ := time.Parse("2006-01-02", startDate)
+					if t.Date.Before(startDateTime) {
+						continue
+					}
+				}
+				if endDate != "" {
+					endDateTime, _ := time.Parse("2006-01-02", endDate)
+					if t.Date.After(endDateTime) {
+						continue
+					}
+				}
+
+				// Only transfers name an account, e.g. [Checking]/Class
+				category, _ := utils.SplitCategoryAndTag(t.Category)
+				transferAccount, _ := qif.TransferAccount(category)
+
+				records = append(records, InvestmentRecord{
+					Date:            t.Date.Format("2006-01-02"),
+					Account:         account.Name,
+					Action:          t.Action,
+					Security:        t.Security,
+					Quantity:        strings.ReplaceAll(t.Quantity, ",", ""),
+					Price:           strings.ReplaceAll(t.Price, ",", ""),
+					Commission:      formatAmount(t.Commission),
+					Amount:          formatAmount(t.Amount),
+					TransferAmount:  formatAmount(t.TransferAmount),
+					TransferAccount: transferAccount,
+					Memo:            t.Memo,
+				})
+			}
+
+			ext := ".csv"
+			switch strings.ToUpper(outputFormat) {
+			case "JSON":
+				ext = ".json"
+			case "XML":
+				ext = ".xml"
+			}
+			outputFileName := fmt.Sprintf("%s_investments%s", account.Name, ext)
+			fullPath := filepath.Join(outputPath, outputFileName)
+
+			if err := writeInvestments(fullPath, records); err != nil {
+				fmt.Printf("Error writing %s: %v\n", outputFileName, err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s: %d investment transactions written to %s\n", account.Name, len(records), outputFileName)
+			exported++
+		}
+
+		if exported == 0 {
+			fmt.Println("No investment accounts found.")
+			return
+		}
+		fmt.Println("\nInvestment export completed successfully!")
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(investmentsCmd)
+
+	investmentsCmd.Flags().StringVarP(&outputFormat, "outputFormat", "f", "CSV", "Output format (CSV, JSON, XML).")
+}
+
+// writeInvestments writes the investment records of one account in the selected output format
+func writeInvestments(path string, records []InvestmentRecord) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch strings.ToUpper(outputFormat) {
+	case "JSON":
+		jsonData, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(jsonData)
+		return err
+	case "XML":
+		xmlData, err := xml.MarshalIndent(investmentList{Investments: records}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := file.WriteString(xml.Header); err != nil {
+			return err
+		}
+		_, err = file.Write(xmlData)
+		return err
+	}
+
+	if err := writeHeader(file, investmentColumns+"\n"); err != nil {
+		return err
+	}
+	for _, r := range records {
+		line := quoteCSVRow([]string{r.Date, r.Account, r.Action, r.Security, r.Quantity, r.Price,
+			r.Commission, r.Amount, r.TransferAmount, r.TransferAccount, r.Memo})
+		if err := writeTransaction(file, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatAmount formats a QIF amount with exactly 2 decimal places, leaving
+// blank or unparseable values as they are
+func formatAmount(amount string) string {
+	amount = strings.ReplaceAll(amount, ",", "")
+	if amount == "" {
+		return ""
+	}
+	if amountValue, err := money.Parse(amount); err == nil {
+		return amountValue.String()
+	}
+	return amount
+}
+
+// containsString reports whether list contains value
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"qifutil/test"
+)
+
+func TestInvestmentsCSV(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "investments.qif")
+	helper.CopyTestData("investments.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	helper.CaptureOutput(func() {
+		investmentsCmd.Run(investmentsCmd, []string{})
+	})
+
+	brokerageFile := filepath.Join(outputDir, "Brokerage_investments.csv")
+	helper.AssertFileExists(brokerageFile)
+	helper.AssertFileContains(brokerageFile, investmentColumns+"\n")
+	helper.AssertFileContains(brokerageFile, `"2023-03-01","Brokerage","Buy","Vanguard Total Stock Market","10","101.25","4.95","1017.45","","",""`)
+	helper.AssertFileContains(brokerageFile, `"2023-04-03","Brokerage","Sell","Vanguard Total Stock Market","5","110.00","4.95","545.05","545.05","Checking Account","Partial sale"`)
+}
+
+func TestInvestmentsTransferAccount(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "investments.qif")
+	os.WriteFile(sourceFile, []byte("!Account\nNBrokerage\nTInvst\n^\n!Type:Invst\n"+
+		"D3/1/2023\nNDiv\nYVTSAX\nT12.00\nLDividend Income\n^\n"+
+		"D3/2/2023\nNXOut\nT100.00\nL[Checking]/Retirement\n$100.00\n^\n"), 0644)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	helper.CaptureOutput(func() {
+		investmentsCmd.Run(investmentsCmd, []string{})
+	})
+
+	// Only transfers fill the Transfer Account column, without their class
+	brokerageFile := filepath.Join(outputDir, "Brokerage_investments.csv")
+	helper.AssertFileContains(brokerageFile, `"2023-03-01","Brokerage","Div","VTSAX","","","","12.00","","",""`)
+	helper.AssertFileContains(brokerageFile, `"2023-03-02","Brokerage","XOut","","","","","100.00","100.00","Checking",""`)
+}
+
+func TestInvestmentsJSONDateFilter(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "investments.qif")
+	helper.CopyTestData("investments.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = "2023-03-10"
+	endDate = ""
+	outputFormat = "JSON"
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() { startDate = "" }()
+
+	helper.CaptureOutput(func() {
+		investmentsCmd.Run(investmentsCmd, []string{})
+	})
+
+	brokerageFile := filepath.Join(outputDir, "Brokerage_investments.json")
+	helper.AssertFileExists(brokerageFile)
+	helper.AssertFileContains(brokerageFile, `"action": "ReinvDiv"`)
+
+	content, _ := os.ReadFile(brokerageFile)
+	if strings.Contains(string(content), `"action": "Buy"`) {
+		t.Error("Expected the March 1 buy to be filtered out")
+	}
+}
@@ -0,0 +1,367 @@
+/*
+Copyright © 2025 Chris Gelhaus <chrisgelhaus@live.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"qifutil/pkg/mapping"
+	"qifutil/pkg/money"
+	"qifutil/pkg/qif"
+	"qifutil/pkg/utils"
+)
+
+// ledgerFormats maps each plain-text accounting format to its file extension
+var ledgerFormats = map[string]string{
+	"LEDGER":    ".ledger",
+	"HLEDGER":   ".journal",
+	"BEANCOUNT": ".beancount",
+}
+
+func init() {
+	descriptions := map[string]string{
+		"LEDGER":    "Ledger journal with account directives",
+		"HLEDGER":   "hledger journal with account directives",
+		"BEANCOUNT": "Beancount ledger with open directives",
+	}
+	for name := range ledgerFormats {
+		registerFormat(exportFormat{
+			name:              name,
+			description:       descriptions[name],
+			wholeTransactions: true,
+			pairTransfers:     true,
+			noImportTag:       true,
+			new: func(s *exportSession, account exportAccount) Exporter {
+				return &ledgerExporter{book: s.book, account: account}
+			},
+			start: func(s *exportSession) error {
+				// Transfers and categories are placed using the whole
+				// file's registers and category list
+				var err error
+				s.book, err = newLedgerBook(s.format.name, s.inputFile, s.accountMapping, s.categoryMapping)
+				return err
+			},
+			finish: func(s *exportSession) error {
+				mainFile, err := s.book.writeMain(s.outputPath, s.inputFile)
+				if err == nil {
+					fmt.Printf("\nJournal: %s\n", mainFile)
+				}
+				return err
+			},
+		})
+	}
+}
+
+// ledgerExporter writes records as entries of a plain-text accounting journal
+type ledgerExporter struct {
+	book    *ledgerBook
+	account exportAccount
+	w       io.Writer
+}
+
+func (e *ledgerExporter) Begin(w io.Writer, name string) error {
+	// Account directives go in the main journal, which includes this file
+	e.w = w
+	e.book.files = append(e.book.files, name)
+	return nil
+}
+
+func (e *ledgerExporter) WriteRecord(record TransactionRecord) error {
+	return e.book.writeEntry(e.w, e.account.outputName, e.account.accountType, record.transaction)
+}
+
+func (e *ledgerExporter) End() error { return nil }
+
+func (e *ledgerExporter) Ext() string { return e.book.ext() }
+
+// ledgerBook is the journal the plain-text accounting formats build across
+// every account's files. It names the accounts postings go to, remembers
+// when each was first used for its open directive, and writes each transfer
+// once even though it appears in the registers of both accounts.
+type ledgerBook struct {
+	format string               // LEDGER, HLEDGER or BEANCOUNT
+	types  map[string]string    // QIF register type, by account name after mapping
+	income map[string]bool      // Income flag from the category list, by category after mapping
+	opened map[string]time.Time // Date of the first posting, by ledger account
+	files  []string             // Account files, in the order they were created
+}
+
+// ledgerPosting is one line of a journal entry
+type ledgerPosting struct {
+	account   string
+	amount    money.Amount
+	hasAmount bool   // False leaves the amount for the journal to balance
+	memo      string // Split memo, written as a comment
+}
+
+// newLedgerBook reads the file's registers and category list so that
+// transfers and categories can be placed under the right account root
+func newLedgerBook(format, path string, accountMapping, categoryMapping *mapping.Mapping) (*ledgerBook, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	b := &ledgerBook{
+		format: strings.ToUpper(format),
+		types:  make(map[string]string),
+		income: make(map[string]bool),
+		opened: make(map[string]time.Time),
+	}
+	reader := qif.NewReader(file, qifOptions())
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case entry.Category != nil:
+			b.income[listName(entry.Category.Name, categoryMapping)] = entry.Category.Income
+		case entry.Account != nil && entry.Transaction == nil && entry.Investment == nil:
+			b.types[listName(entry.Account.Name, accountMapping)] = entry.Account.Type
+		}
+	}
+}
+
+// ext returns the extension of the format's files
+func (b *ledgerBook) ext() string {
+	return ledgerFormats[b.format]
+}
+
+// registerAccount returns the ledger account of a QIF account: credit card
+// and other liability registers go under Liabilities, the rest under Assets
+func (b *ledgerBook) registerAccount(name, qifType string) string {
+	root := "Assets"
+	switch strings.ToLower(qifType) {
+	case "ccard", "oth l":
+		root = "Liabilities"
+	}
+	return b.accountName(root + ":" + name)
+}
+
+// categoryAccount returns the ledger account a register amount is posted
+// against. Income and expenses follow the category list, or the sign of
+// the amount for categories it doesn't list.
+func (b *ledgerBook) categoryAccount(category, account string, amount money.Amount) string {
+	if counterpart, ok := qif.TransferAccount(category); ok {
+		// A transfer to the account itself is Quicken's opening balance
+		if counterpart == account {
+			return b.accountName("Equity:Opening Balances")
+		}
+		return b.registerAccount(counterpart, b.types[counterpart])
+	}
+	if category == "" || category == "--Split--" {
+		category = "Uncategorized"
+	}
+	income, listed := b.income[category]
+	if !listed {
+		income = amount > 0
+	}
+	root := "Expenses"
+	if income {
+		root = "Income"
+	}
+	// Quicken's own income categories are already named Income:...
+	if top, rest, _ := strings.Cut(category, ":"); strings.EqualFold(top, root) && rest != "" {
+		category = rest
+	}
+	return b.accountName(root + ":" + category)
+}
+
+// accountName makes a colon-separated account path valid for the format.
+// Beancount only allows letters, digits and dashes, and each component
+// must start with a capital letter or digit.
+func (b *ledgerBook) accountName(path string) string {
+	components := strings.Split(path, ":")
+	for i, c := range components {
+		if b.format == "BEANCOUNT" {
+			c = strings.Map(func(r rune) rune {
+				if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' {
+					return r
+				}
+				return '-'
+			}, c)
+			for strings.Contains(c, "--") {
+				c = strings.ReplaceAll(c, "--", "-")
+			}
+			c = strings.Trim(c, "-")
+			if c != "" {
+				runes := []rune(c)
+				runes[0] = unicode.ToUpper(runes[0])
+				c = string(runes)
+			}
+		} else {
+			// Two spaces end the account name of a ledger posting
+			c = strings.Join(strings.Fields(c), " ")
+		}
+		if c == "" {
+			c = "Other"
+		}
+		components[i] = c
+	}
+	return strings.Join(components, ":")
+}
+
+// use remembers the first date an account was posted to
+func (b *ledgerBook) use(account string, date time.Time) {
+	if first, ok := b.opened[account]; !ok || date.Before(first) {
+		b.opened[account] = date
+	}
+}
+
+// writeEntry writes a transaction of the named register as a journal entry:
+// a posting to the register's account and one against its category, or one
+// per split line
+func (b *ledgerBook) writeEntry(w io.Writer, account, accountType string, t *qif.Transaction) error {
+	date := t.Date.Format("2006-01-02")
+	amount, err := money.Parse(t.Amount)
+	if err != nil {
+		_, err = fmt.Fprintf(w, "; %s %s: left out, the amount %q can't be read\n\n", date, t.Payee, t.Amount)
+		return err
+	}
+
+	category, tags := utils.SplitCategoryAndTag(t.Category)
+	postings := []ledgerPosting{{b.registerAccount(account, accountType), amount, true, ""}}
+	if len(t.Splits) == 0 {
+		postings = append(postings, ledgerPosting{b.categoryAccount(category, account, amount), -amount, true, ""})
+	}
+	for _, split := range t.Splits {
+		splitCategory, _ := utils.SplitCategoryAndTag(split.Category)
+		splitAmount, err := money.Parse(split.Amount)
+		postings = append(postings, ledgerPosting{b.categoryAccount(splitCategory, account, splitAmount), -splitAmount, err == nil, split.Memo})
+	}
+
+	var tagNames []string
+	for _, tag := range strings.Split(tags, ":") {
+		if tag = strings.Join(strings.Fields(tag), "-"); tag != "" {
+			tagNames = append(tagNames, tag)
+		}
+	}
+	cleared := t.Cleared != ""
+
+	var s strings.Builder
+	if b.format == "BEANCOUNT" {
+		flag := "!"
+		if cleared {
+			flag = "*"
+		}
+		fmt.Fprintf(&s, "%s %s %s %s", date, flag, beancountString(t.Payee), beancountString(t.Memo))
+		for _, tag := range tagNames {
+			s.WriteString(" #" + strings.Map(beancountTagRune, tag))
+		}
+		s.WriteString("\n")
+		if t.Number != "" {
+			fmt.Fprintf(&s, "  check: %s\n", beancountString(t.Number))
+		}
+		for _, p := range postings {
+			b.use(p.account, t.Date)
+			line := "  " + p.account
+			if p.hasAmount {
+				line = fmt.Sprintf("  %-48s  %s USD", p.account, p.amount)
+			}
+			s.WriteString(withComment(line, p.memo))
+		}
+	} else {
+		s.WriteString(date)
+		if cleared {
+			s.WriteString(" *")
+		}
+		if t.Number != "" {
+			s.WriteString(" (" + t.Number + ")")
+		}
+		if t.Payee != "" {
+			s.WriteString(" " + t.Payee)
+		}
+		s.WriteString("\n")
+		if t.Memo != "" {
+			s.WriteString("    ; " + t.Memo + "\n")
+		}
+		if len(tagNames) > 0 {
+			if b.format == "HLEDGER" {
+				s.WriteString("    ; " + strings.Join(tagNames, ":, ") + ":\n")
+			} else {
+				s.WriteString("    ; :" + strings.Join(tagNames, ":") + ":\n")
+			}
+		}
+		for _, p := range postings {
+			b.use(p.account, t.Date)
+			line := "    " + p.account
+			if p.hasAmount {
+				line = fmt.Sprintf("    %-48s  $%s", p.account, p.amount)
+			}
+			s.WriteString(withComment(line, p.memo))
+		}
+	}
+	s.WriteString("\n")
+
+	_, err = io.WriteString(w, s.String())
+	return err
+}
+
+// writeMain writes main.<ext>, the journal to open: a directive for every
+// account posted to, followed by an include of every account file
+func (b *ledgerBook) writeMain(dir, source string) (string, error) {
+	accounts := make([]string, 0, len(b.opened))
+	for account := range b.opened {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	var s strings.Builder
+	fmt.Fprintf(&s, "; Written by qifutil from %s\n", filepath.Base(source))
+	if b.format == "BEANCOUNT" {
+		s.WriteString("option \"operating_currency\" \"USD\"\n\n")
+		for _, account := range accounts {
+			fmt.Fprintf(&s, "%s open %s USD\n", b.opened[account].Format("2006-01-02"), account)
+		}
+	} else {
+		s.WriteString("\n")
+		for _, account := range accounts {
+			fmt.Fprintf(&s, "account %s\n", account)
+		}
+	}
+	s.WriteString("\n")
+	for _, name := range b.files {
+		if b.format == "BEANCOUNT" {
+			fmt.Fprintf(&s, "include %s\n", beancountString(name))
+		} else {
+			fmt.Fprintf(&s, "include %s\n", name)
+		}
+	}
+
+	path := filepath.Join(dir, "main"+b.ext())
+	return path, os.WriteFile(path, []byte(s.String()), 0644)
+}
+
+// withComment ends a posting line, adding the comment if there is one
+func withComment(line, comment string) string {
+	if comment != "" {
+		line += "  ; " + comment
+	}
+	return line + "\n"
+}
+
+// beancountString quotes a value as a Beancount string
+func beancountString(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// beancountTagRune replaces the characters a Beancount tag can't hold
+func beancountTagRune(r rune) rune {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_/.", r) {
+		return r
+	}
+	return '-'
+}
@@ -6,8 +6,8 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"regexp"
-	"strings"
+
+	"qifutil/pkg/qif"
 
 	"github.com/spf13/cobra"
 )
@@ -23,7 +23,7 @@ DESCRIPTION:
   This command is typically used before running other commands to:
   - View available accounts for export
   - Verify account names for filtering
-  - Check account types (Bank, Credit Card, etc.)
+  - Check account types (Bank, CCard, Cash, Invst, Oth A, Oth L, Invoice)
 
 USAGE EXAMPLES:
   1. List account names only:
@@ -52,43 +52,26 @@ TIPS:
 
 		fmt.Printf("Reading accounts from %s...\n\n", inputFile)
 
-		// Load input file
-		inputBytes, err := os.ReadFile(inputFile)
+		// Parse the input file
+		qifFile, err := qif.ParseFile(inputFile, qifOptions())
 		if err != nil {
 			fmt.Printf("Error reading file: %v\n", err)
 			return
 		}
 
-		inputContent := string(inputBytes)
-		// Standardize Line Endings
-		inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
-
-		// Regex pattern to match account blocks
-		accountBlockHeaderRegex := `(?m)^!Account[^\n]*\n^N(.*?)\n^T(.*?)\n^\^\n^!Type:(Bank|CCard)\s*\n`
-		regex, err := regexp.Compile(accountBlockHeaderRegex)
-		if err != nil {
-			fmt.Println("Error compiling regex:", err)
-			return
-		}
-
-		// Find all account blocks
-		accountBlocks := regex.FindAllStringSubmatch(inputContent, -1)
-		if len(accountBlocks) == 0 {
+		if len(qifFile.Accounts) == 0 {
 			fmt.Println("No accounts found in the file.")
 			return
 		}
 
-		fmt.Printf("Found %d accounts in %s:\n\n", len(accountBlocks), inputFile)
+		fmt.Printf("Found %d accounts in %s:\n\n", len(qifFile.Accounts), inputFile)
 
 		// Print each account
-		for i, match := range accountBlocks {
-			accountName := strings.TrimSpace(match[1])
-			accountType := strings.TrimSpace(match[3])
-
+		for i, account := range qifFile.Accounts {
 			if showTypes {
-				fmt.Printf("%d. %s (Type: %s)\n", i+1, accountName, accountType)
+				fmt.Printf("%d. %s (Type: %s)\n", i+1, account.Name, account.Type)
 			} else {
-				fmt.Printf("%d. %s\n", i+1, accountName)
+				fmt.Printf("%d. %s\n", i+1, account.Name)
 			}
 		}
 	},
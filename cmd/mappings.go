@@ -0,0 +1,251 @@
+/*
+Copyright © 2025 Chris Gelhaus <chrisgelhaus@live.com>
+*/
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"qifutil/pkg/mapping"
+	"qifutil/pkg/qif"
+	"qifutil/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// starterMapping is one generated mapping file
+type starterMapping struct {
+	kind     string         // Category, Payee, Tag or Account
+	fileName string         // Name of the generated file
+	existing *string        // Flag holding the existing mapping file, if any
+	counts   map[string]int // Occurrences of each distinct value
+}
+
+// mappingsCmd represents the mappings export command
+var mappingsCmd = &cobra.Command{
+	Use:   "mappings",
+	Short: "Generate starter mapping files from the values in a QIF file",
+	Long: `Generate starter category, payee, tag and account mapping files.
+
+Each file lists every distinct value found in the transactions, with an
+empty target and the number of times the value appears:
+
+  "Whole Foods Market","","42"
+
+Fill in the targets and pass the files to 'qifutil export transactions'.
+Rows with an empty target are ignored, as is the count column.
+
+If existing mapping files are given, the generated file keeps all of their
+lines and only adds the values none of their rules cover yet.
+
+EXAMPLE:
+  qifutil export mappings --inputFile data.qif --outputPath ./maps
+
+  # Add values from a newer export to the mapping files you already have
+  qifutil export mappings --inputFile data.qif --outputPath ./maps \
+    --categoryMapFile ./maps/categoryMap.csv --payeeMapFile ./maps/payeeMap.csv
+
+FILES:
+  categoryMap.csv, payeeMap.csv, tagMap.csv, accountMap.csv`,
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if inputFile == "" {
+			fmt.Println("Error: Missing required flag --inputFile")
+			os.Exit(1)
+		}
+		if outputPath == "" {
+			fmt.Println("Error: Missing required flag --outputPath")
+			os.Exit(1)
+		}
+
+		// Validate date format if provided
+		dateFormat := "2006-01-02"
+		if startDate != "" {
+			if _, err := time.Parse(dateFormat, startDate); err != nil {
+				fmt.Println("Error: Invalid start date format. Use YYYY-MM-DD")
+				os.Exit(1)
+			}
+		}
+		if endDate != "" {
+			if _, err := time.Parse(dateFormat, endDate); err != nil {
+				fmt.Println("Error: Invalid end date format. Use YYYY-MM-DD")
+				os.Exit(1)
+			}
+		}
+	},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Generating mapping files...")
+
+		// Create the output directory
+		outputPath = filepath.Clean(outputPath)
+		if mkdirErr := os.MkdirAll(outputPath, 0755); mkdirErr != nil {
+			fmt.Printf("Error creating output directory: %v\n", mkdirErr)
+			os.Exit(1)
+		}
+
+		// Process the selected accounts into a list
+		var selectedAccountList []string
+		if selectedAccounts != "" {
+			selectedAccountList = strings.Split(selectedAccounts, ",")
+			for i := range selectedAccountList {
+				selectedAccountList[i] = strings.TrimSpace(selectedAccountList[i])
+			}
+		}
+
+		categories := &starterMapping{kind: "Category", fileName: "categoryMap.csv", existing: &categoryMappingFile}
+		payees := &starterMapping{kind: "Payee", fileName: "payeeMap.csv", existing: &payeeMappingFile}
+		tags := &starterMapping{kind: "Tag", fileName: "tagMap.csv", existing: &tagMappingFile}
+		accounts := &starterMapping{kind: "Account", fileName: "accountMap.csv", existing: &accountMappingFile}
+		starters := []*starterMapping{categories, payees, tags, accounts}
+		for _, starter := range starters {
+			starter.counts = make(map[string]int)
+		}
+
+		input, err := os.Open(inputFile)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			os.Exit(1)
+		}
+		defer input.Close()
+
+		// Count every value the transactions export would look up in a mapping file
+		reader := qif.NewReader(input, qifOptions())
+		for {
+			entry, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				fmt.Println("Error reading file:", err)
+				os.Exit(1)
+			}
+			t := entry.Transaction
+			if t == nil {
+				continue
+			}
+			if len(selectedAccountList) > 0 && !containsString(selectedAccountList, entry.Account.Name) {
+				continue
+			}
+			if !inDateRange(t.Date) {
+				continue
+			}
+
+			accounts.counts[entry.Account.Name]++
+			// Written as the transactions command looks it up
+			if t.Payee != "" {
+				payees.counts[t.Payee]++
+			}
+
+			lines := []string{t.Category}
+			for _, split := range t.Splits {
+				lines = append(lines, split.Category)
+			}
+			for _, line := range lines {
+				category, tag := utils.SplitCategoryAndTag(line)
+				// Transfers are exported with --transferCategory rather than mapped
+				if _, isTransfer := qif.TransferAccount(category); !isTransfer && category != "" {
+					categories.counts[category]++
+				}
+				if tag != "" {
+					tags.counts[tag]++
+				}
+			}
+		}
+
+		for _, starter := range starters {
+			added, err := writeStarterMapping(starter)
+			if err != nil {
+				fmt.Printf("Error writing %s: %v\n", starter.fileName, err)
+				os.Exit(1)
+			}
+			if *starter.existing != "" {
+				fmt.Printf("%s: %d new %s values added to the rules from %s\n", starter.fileName, added, strings.ToLower(starter.kind), *starter.existing)
+			} else {
+				fmt.Printf("%s: %d %s values\n", starter.fileName, added, strings.ToLower(starter.kind))
+			}
+		}
+
+		fmt.Println("\nMapping files generated successfully!")
+	},
+}
+
+func init() {
+	exportCmd.AddCommand(mappingsCmd)
+
+	mappingsCmd.Flags().StringVarP(&categoryMappingFile, "categoryMapFile", "c", "", "Existing category mapping file to extend. Optional.")
+	mappingsCmd.Flags().StringVarP(&payeeMappingFile, "payeeMapFile", "p", "", "Existing payee mapping file to extend. Optional.")
+	mappingsCmd.Flags().StringVarP(&tagMappingFile, "tagMapFile", "t", "", "Existing tag mapping file to extend. Optional.")
+	mappingsCmd.Flags().StringVarP(&accountMappingFile, "accountMapFile", "a", "", "Existing account mapping file to extend. Optional.")
+}
+
+// writeStarterMapping writes one generated mapping file and returns how many
+// values it added. Lines of an existing mapping file are kept as they are,
+// and only values its rules don't cover are added, most frequent first.
+func writeStarterMapping(starter *starterMapping) (int, error) {
+	var existingLines []byte
+	existing := mapping.New()
+	if *starter.existing != "" {
+		var err error
+		if existingLines, err = os.ReadFile(*starter.existing); err != nil {
+			return 0, err
+		}
+		if existing, err = mapping.Read(strings.NewReader(string(existingLines))); err != nil {
+			return 0, err
+		}
+	}
+
+	// Sources already listed with an empty target count as covered too, so
+	// running the command again doesn't repeat them
+	listed := make(map[string]bool)
+	reader := csv.NewReader(strings.NewReader(string(existingLines)))
+	reader.FieldsPerRecord = -1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(record) >= 2 {
+			listed[record[0]] = true
+		}
+	}
+
+	var values []string
+	for value := range starter.counts {
+		if existing.Match(value) == nil && !listed[value] {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if starter.counts[values[i]] != starter.counts[values[j]] {
+			return starter.counts[values[i]] > starter.counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+
+	var out strings.Builder
+	out.Write(existingLines)
+	if len(existingLines) > 0 && !strings.HasSuffix(string(existingLines), "\n") {
+		out.WriteString("\n")
+	}
+	for _, value := range values {
+		out.WriteString(quoteCSVRow([]string{value, "", strconv.Itoa(starter.counts[value])}))
+	}
+
+	path := filepath.Join(outputPath, starter.fileName)
+	if err := os.WriteFile(path, []byte(out.String()), 0644); err != nil {
+		return 0, err
+	}
+	return len(values), nil
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"qifutil/test"
+)
+
+func TestMappingsStarterFiles(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+
+	sourceFile := filepath.Join(tempDir, "sample.qif")
+	helper.CopyTestData("sample.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	helper.CaptureOutput(func() {
+		mappingsCmd.Run(mappingsCmd, []string{})
+	})
+
+	for _, name := range []string{"categoryMap.csv", "payeeMap.csv", "tagMap.csv", "accountMap.csv"} {
+		helper.AssertFileExists(filepath.Join(outputDir, name))
+	}
+
+	categoryFile := filepath.Join(outputDir, "categoryMap.csv")
+	content, _ := os.ReadFile(categoryFile)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	// Most frequent value first
+	if lines[0] != `"Food:Dining","","10"` {
+		t.Errorf("Expected Food:Dining first, got %s", lines[0])
+	}
+	helper.AssertFileContains(filepath.Join(outputDir, "accountMap.csv"), `"Checking Account","","35"`)
+	helper.AssertFileContains(filepath.Join(outputDir, "payeeMap.csv"), `"Monthly Savings Transfer","","3"`)
+}
+
+func TestMappingsExtendExistingFile(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+
+	sourceFile := filepath.Join(tempDir, "sample.qif")
+	helper.CopyTestData("sample.qif", sourceFile)
+
+	existingFile := filepath.Join(tempDir, "categories.csv")
+	os.WriteFile(existingFile, []byte(`"Food:Dining","Restaurants"`+"\n"+`"re:^Travel:","Travel"`+"\n"), 0644)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	inputFile = sourceFile
+	outputPath = outputDir
+	categoryMappingFile = existingFile
+	defer func() { categoryMappingFile = "" }()
+
+	helper.CaptureOutput(func() {
+		mappingsCmd.Run(mappingsCmd, []string{})
+	})
+
+	categoryFile := filepath.Join(outputDir, "categoryMap.csv")
+	content, _ := os.ReadFile(categoryFile)
+	text := string(content)
+	if !strings.HasPrefix(text, `"Food:Dining","Restaurants"`+"\n"+`"re:^Travel:","Travel"`+"\n") {
+		t.Errorf("Expected existing rules to be kept, got:\n%s", text)
+	}
+	if strings.Contains(text, `"Food:Dining",""`) || strings.Contains(text, `"Travel:Hotels"`) {
+		t.Errorf("Expected covered values to be left out, got:\n%s", text)
+	}
+	helper.AssertFileContains(categoryFile, `"Income:Salary","","6"`)
+
+	// A second run with the generated file adds nothing new
+	categoryMappingFile = categoryFile
+	helper.CaptureOutput(func() {
+		mappingsCmd.Run(mappingsCmd, []string{})
+	})
+	again, _ := os.ReadFile(categoryFile)
+	if string(again) != text {
+		t.Errorf("Expected second run to leave the file unchanged, got:\n%s", again)
+	}
+}
+
+func TestMappingsQuotedPayees(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+
+	sourceFile := filepath.Join(tempDir, "payees.qif")
+	os.WriteFile(sourceFile, []byte("!Account\nNChecking\nTBank\n^\n!Type:Bank\n"+
+		"D1/5/2024\nT-20.00\nPWhole Foods, Inc.\n^\n"+
+		"D1/6/2024\nT-5.00\nPSay \"Hi\" Cafe\n^\n"), 0644)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	helper.CaptureOutput(func() {
+		mappingsCmd.Run(mappingsCmd, []string{})
+	})
+
+	payeeFile := filepath.Join(outputDir, "payeeMap.csv")
+	helper.AssertFileContains(payeeFile, `"Whole Foods, Inc.","","1"`)
+	// Kept as the payee is looked up, with its quotes escaped
+	helper.AssertFileContains(payeeFile, `"Say ""Hi"" Cafe","","1"`)
+	first, _ := os.ReadFile(payeeFile)
+
+	payeeMappingFile = payeeFile
+	defer func() { payeeMappingFile = "" }()
+	helper.CaptureOutput(func() {
+		mappingsCmd.Run(mappingsCmd, []string{})
+	})
+	again, _ := os.ReadFile(payeeFile)
+	if string(again) != string(first) {
+		t.Errorf("Expected second run to leave the file unchanged, got:\n%s", again)
+	}
+}
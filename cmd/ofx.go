@@ -0,0 +1,299 @@
+/*
+Copyright © 2025 Chris Gelhaus <chrisgelhaus@live.com>
+*/
+package cmd
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"qifutil/pkg/money"
+	"qifutil/pkg/qif"
+)
+
+func init() {
+	for _, version := range []int{1, 2} {
+		name, description := "OFX", "OFX 1.x (SGML) bank or credit card statement"
+		if version == 2 {
+			name, description = "OFX2", "OFX 2.x (XML) bank or credit card statement"
+		}
+		registerFormat(exportFormat{
+			name:        name,
+			description: description,
+			// A statement lists each bank entry once, as the bank would
+			wholeTransactions: true,
+			new: func(s *exportSession, account exportAccount) Exporter {
+				return &ofxExporter{version: version, account: account, fitids: make(ofxFITIDs)}
+			},
+		})
+	}
+}
+
+// ofxExporter writes each of an account's files as one OFX statement
+type ofxExporter struct {
+	version   int
+	account   exportAccount
+	fitids    ofxFITIDs     // OFX transaction IDs handed out so far
+	total     money.Amount  // Sum of the amounts written so far, in every file
+	statement *ofxStatement // Statement of the current file
+	w         io.Writer
+}
+
+func (e *ofxExporter) Begin(w io.Writer, name string) error {
+	// Written in full when the file ends
+	e.w = w
+	e.statement = &ofxStatement{version: e.version, account: e.account.outputName, qifType: e.account.accountType, dtServer: time.Now()}
+	return nil
+}
+
+func (e *ofxExporter) WriteRecord(record TransactionRecord) error {
+	e.statement.records = append(e.statement.records, record)
+	e.statement.fitids = append(e.statement.fitids, e.fitids.next(e.account.name, record.source))
+	if amount, err := money.Parse(record.Amount); err == nil {
+		e.total += amount
+	}
+	return nil
+}
+
+func (e *ofxExporter) End() error {
+	// The account's stated balance, read by now even when the account list
+	// follows the register, or else the total of the transactions written
+	e.statement.balance = e.total
+	if account := e.account.source; account != nil {
+		if balance, err := money.Parse(account.Balance); err == nil {
+			e.statement.balance = balance
+			e.statement.balanceDate = account.BalanceDate
+			e.statement.stated = true
+		}
+	}
+	err := e.statement.write(e.w)
+	e.statement = nil
+	return err
+}
+
+func (e *ofxExporter) Ext() string { return ".ofx" }
+
+// ofxStatement is one account's statement in an OFX file. OFX states the
+// statement's date range before its transactions, so records are collected
+// and written when the file is finished.
+type ofxStatement struct {
+	version  int    // 1 for OFX 1.x SGML, 2 for OFX 2.x XML
+	account  string // Account name written as the account ID
+	qifType  string // QIF register type, e.g. Bank or CCard
+	records  []TransactionRecord
+	fitids   []string
+	dtServer time.Time
+
+	// balance is the ledger balance. When the QIF file states one it is
+	// as of balanceDate or, without one, when the file was written.
+	// Otherwise it is the total of the transactions written, as of the
+	// statement's end date.
+	balance     money.Amount
+	balanceDate time.Time
+	stated      bool
+}
+
+// ofxFITIDs hands out transaction IDs that stay the same when the same
+// QIF file is exported again: a hash of the transaction's fields as read
+// from the file, with a counter for identical transactions in the same
+// account. Mappings and rules don't change them, so an app that already
+// imported a statement recognizes its transactions.
+type ofxFITIDs map[string]int
+
+// next returns the FITID for a transaction of the named account
+func (ids ofxFITIDs) next(account string, t *qif.Transaction) string {
+	h := sha1.New()
+	for _, field := range []string{account, t.Date.Format("2006-01-02"), t.Amount, t.Payee, t.Memo, t.Number} {
+		io.WriteString(h, field)
+		h.Write([]byte{0})
+	}
+	id := hex.EncodeToString(h.Sum(nil))[:20]
+	ids[id]++
+	if n := ids[id]; n > 1 {
+		return fmt.Sprintf("%s-%d", id, n)
+	}
+	return id
+}
+
+// ofxAccountType returns the OFX ACCTTYPE for a QIF bank-style register
+func ofxAccountType(qifType string) string {
+	switch strings.ToLower(qifType) {
+	case "oth a":
+		return "SAVINGS"
+	case "oth l":
+		return "CREDITLINE"
+	}
+	return "CHECKING"
+}
+
+// ofxDate formats a YYYY-MM-DD date as an OFX date
+func ofxDate(date string) string {
+	return strings.ReplaceAll(date, "-", "")
+}
+
+// ofxText escapes the characters OFX reserves and trims the value to the
+// field's maximum length
+func ofxText(value string, max int) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if utf8.RuneCountInString(value) > max {
+		value = string([]rune(value)[:max])
+	}
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(value)
+}
+
+// dateRange returns the statement's start and end dates: --startDate and
+// --endDate when given, otherwise the dates of its first and last records
+func (s *ofxStatement) dateRange() (string, string) {
+	start, end := startDate, endDate
+	for _, record := range s.records {
+		if startDate == "" && (start == "" || record.Date < start) {
+			start = record.Date
+		}
+		if endDate == "" && (end == "" || record.Date > end) {
+			end = record.Date
+		}
+	}
+	today := s.dtServer.Format("2006-01-02")
+	if start == "" {
+		start = today
+	}
+	if end == "" {
+		end = today
+	}
+	return start, end
+}
+
+// write writes the statement as a complete OFX document
+func (s *ofxStatement) write(w io.Writer) error {
+	var b strings.Builder
+
+	// element writes a data element; OFX 1.x SGML leaves them unclosed
+	element := func(name, value string) {
+		b.WriteString("<" + name + ">" + value)
+		if s.version == 2 {
+			b.WriteString("</" + name + ">")
+		}
+		b.WriteString("\n")
+	}
+	open := func(name string) { b.WriteString("<" + name + ">\n") }
+	end := func(name string) { b.WriteString("</" + name + ">\n") }
+
+	if s.version == 2 {
+		b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+		b.WriteString("<?OFX OFXHEADER=\"200\" VERSION=\"211\" SECURITY=\"NONE\" OLDFILEUID=\"NONE\" NEWFILEUID=\"NONE\"?>\n")
+	} else {
+		b.WriteString("OFXHEADER:100\nDATA:OFXSGML\nVERSION:102\nSECURITY:NONE\nENCODING:UNICODE\nCHARSET:NONE\nCOMPRESSION:NONE\nOLDFILEUID:NONE\nNEWFILEUID:NONE\n\n")
+	}
+
+	creditCard := strings.EqualFold(s.qifType, "CCard")
+	messages, transactionResponse, statement, accountFrom := "BANKMSGSRSV1", "STMTTRNRS", "STMTRS", "BANKACCTFROM"
+	if creditCard {
+		messages, transactionResponse, statement, accountFrom = "CREDITCARDMSGSRSV1", "CCSTMTTRNRS", "CCSTMTRS", "CCACCTFROM"
+	}
+	start, finish := s.dateRange()
+
+	open("OFX")
+	open("SIGNONMSGSRSV1")
+	open("SONRS")
+	open("STATUS")
+	element("CODE", "0")
+	element("SEVERITY", "INFO")
+	end("STATUS")
+	element("DTSERVER", s.dtServer.Format("20060102150405"))
+	element("LANGUAGE", "ENG")
+	end("SONRS")
+	end("SIGNONMSGSRSV1")
+
+	open(messages)
+	open(transactionResponse)
+	element("TRNUID", "0")
+	open("STATUS")
+	element("CODE", "0")
+	element("SEVERITY", "INFO")
+	end("STATUS")
+	open(statement)
+	element("CURDEF", "USD")
+	open(accountFrom)
+	if !creditCard {
+		element("BANKID", "000000000")
+	}
+	element("ACCTID", ofxText(s.account, 22))
+	if !creditCard {
+		element("ACCTTYPE", ofxAccountType(s.qifType))
+	}
+	end(accountFrom)
+
+	open("BANKTRANLIST")
+	element("DTSTART", ofxDate(start))
+	element("DTEND", ofxDate(finish))
+	for i, record := range s.records {
+		open("STMTTRN")
+		element("TRNTYPE", ofxTransactionType(record))
+		element("DTPOSTED", ofxDate(record.Date))
+		element("TRNAMT", record.Amount)
+		element("FITID", s.fitids[i])
+		if record.CheckNumber != "" && isDigits(record.CheckNumber) {
+			element("CHECKNUM", record.CheckNumber)
+		}
+		if record.Merchant != "" {
+			element("NAME", ofxText(record.Merchant, 32))
+		}
+		if record.Notes != "" {
+			element("MEMO", ofxText(record.Notes, 255))
+		}
+		end("STMTTRN")
+	}
+	end("BANKTRANLIST")
+
+	// LEDGERBAL is required, so a file without a stated balance gets the
+	// running total
+	asOf := ofxDate(finish)
+	if s.stated {
+		asOf = s.dtServer.Format("20060102")
+		if !s.balanceDate.IsZero() {
+			asOf = s.balanceDate.Format("20060102")
+		}
+	}
+	open("LEDGERBAL")
+	element("BALAMT", s.balance.String())
+	element("DTASOF", asOf)
+	end("LEDGERBAL")
+	end(statement)
+	end(transactionResponse)
+	end(messages)
+	end("OFX")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ofxTransactionType classifies a record for the TRNTYPE element
+func ofxTransactionType(record TransactionRecord) string {
+	switch {
+	case record.TransferAccount != "":
+		return "XFER"
+	case record.CheckNumber != "" && isDigits(record.CheckNumber):
+		return "CHECK"
+	case strings.HasPrefix(record.Amount, "-"):
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+// isDigits reports whether s is a non-empty string of ASCII digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -9,9 +9,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
+	"qifutil/pkg/qif"
+
 	"github.com/spf13/cobra"
 )
 
@@ -36,8 +37,6 @@ var payeesCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 
 		var payees []string
-		var transactionRegexString string = `D(?<month>\d{1,2})\/(\s?(?<day>\d{1,2}))'(?<year>\d{2})[\r\n]+(U(?<amount1>.*?)[\r\n]+)(T(?<amount2>.*?)[\r\n]+)(C(?<cleared>.*?)[\r\n]+)((N(?<number>.*?)[\r\n]+)?)(P(?<payee>.*?)[\r\n]+)((M(?<memo>.*?)[\r\n]+)?)(L(?<category>.*?)[\r\n]+)`
-		var accountBlockHeaderRegex string = `(?m)^!Account[^\n]*\n^N(.*?)\n^T(.*?)\n^\^\n^!Type:(Bank|CCard)\s*\n`
 
 		// Build output file path using outputPath if provided
 		outputFilePath := payeeOutputFile
@@ -54,61 +53,25 @@ var payeesCmd = &cobra.Command{
 		}
 		defer payeeFile.Close()
 
-		// Load input file
-		inputBytes, err := os.ReadFile(inputFile)
+		// Parse the input file
+		qifFile, err := qif.ParseFile(inputFile, qifOptions())
 		if err != nil {
 			fmt.Println("Error reading file:", err)
-		} else {
-			fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
+			return
 		}
-		inputContent := string(inputBytes)
-
-		// Standardize Line Endings to simplify Regex
-		inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
-
-		// Gather payees from the Accounts
-		// Compile the regex
-		regex, _ := regexp.Compile(accountBlockHeaderRegex)
-
-		accountBlocks := regex.FindAllStringSubmatchIndex(inputContent, -1)
-		if len(accountBlocks) == 0 {
+		if len(qifFile.Accounts) == 0 {
 			fmt.Println("No matches found.")
 		}
 
-		// loop over each account block and pull out payees
-		for _, accountBlock := range accountBlocks {
-			accountName := inputContent[accountBlock[2]:accountBlock[3]]
-
-			restOfText := inputContent[accountBlock[1]:]
-			nextTypePattern := `(?mi)^\s*!Type:.*$`
-			nextTypeRe := regexp.MustCompile(nextTypePattern)
-			nextLoc := nextTypeRe.FindStringIndex(restOfText)
-			var endPos int
-			if nextLoc != nil {
-				endPos = accountBlock[1] + nextLoc[0]
-			} else {
-				endPos = len(inputContent)
-			}
-
-			// Extract the text between the type lines
-			textBetweenTypes := inputContent[accountBlock[1]:endPos]
-
-			// Use the existing pattern to match entries
-			regex, _ := regexp.Compile(transactionRegexString)
+		// loop over each account and pull out payees
+		for _, account := range qifFile.Accounts {
+			fmt.Printf("%d payees extracted from account: %s\n", len(account.Transactions), account.Name)
 
-			// Find all matches in the content.
-			transactions := regex.FindAllStringSubmatch(textBetweenTypes, -1)
-			fmt.Printf("%d payees extracted from account: %s\n", len(transactions), accountName)
-
-			// Loop through matches and add payees to the array
-			for _, t := range transactions {
-				if len(t) > 1 {
-					payee := strings.TrimSpace(t[15])
-					// Remove double quotes
-					payee = strings.ReplaceAll(payee, "\"", "")
-					// Add payee to the list
-					payees = append(payees, payee)
-				}
+			for _, t := range account.Transactions {
+				// Remove double quotes
+				payee := strings.ReplaceAll(t.Payee, "\"", "")
+				// Add payee to the list
+				payees = append(payees, payee)
 			}
 		}
 
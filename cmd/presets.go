@@ -0,0 +1,188 @@
+/*
+Copyright © 2025 Chris Gelhaus <chrisgelhaus@live.com>
+*/
+package cmd
+
+import (
+	"io"
+	"strings"
+	"time"
+)
+
+// csvColumn is one column of a preset: the header the app expects and the
+// record field it holds
+type csvColumn struct {
+	header string
+	field  string // A --csvColumns name, or Inflow, Outflow or Opposing Account
+}
+
+// csvPreset fixes the CSV layout a personal finance app imports
+type csvPreset struct {
+	name         string
+	description  string
+	columns      []csvColumn
+	dateFormat   string // Go layout of the Date column
+	tagSeparator string // Written between tags
+	tagPrefix    string // Written before each tag, e.g. # for hashtags
+	tagsInNotes  bool   // Tags are appended to the Notes column; the app has no tag column
+
+	// maxRecordsPerFile is the number of records per file written for the
+	// app, 0 for one file per account. --recordsPerFile overrides it.
+	maxRecordsPerFile int
+}
+
+// csvPresets are the built-in layouts, registered as output formats
+var csvPresets = []csvPreset{
+	{
+		name:        "MONARCH",
+		description: "Monarch Money CSV import",
+		columns: []csvColumn{
+			{"Date", "Date"}, {"Merchant", "Merchant"}, {"Category", "Category"}, {"Account", "Account"},
+			{"Original Statement", "Original Statement"}, {"Notes", "Notes"}, {"Amount", "Amount"}, {"Tags", "Tags"},
+		},
+		dateFormat:        "2006-01-02",
+		tagSeparator:      ",",
+		maxRecordsPerFile: 5000,
+	},
+	{
+		name:        "YNAB",
+		description: "YNAB file import, with Outflow and Inflow columns",
+		columns: []csvColumn{
+			{"Date", "Date"}, {"Payee", "Merchant"}, {"Memo", "Notes"}, {"Outflow", "Outflow"}, {"Inflow", "Inflow"},
+		},
+		dateFormat: "01/02/2006",
+	},
+	{
+		name:        "ACTUAL",
+		description: "Actual Budget CSV import, with tags as #hashtags in the notes",
+		columns: []csvColumn{
+			{"Date", "Date"}, {"Payee", "Merchant"}, {"Category", "Category"}, {"Notes", "Notes"}, {"Amount", "Amount"},
+		},
+		dateFormat:   "2006-01-02",
+		tagSeparator: " ",
+		tagPrefix:    "#",
+		tagsInNotes:  true,
+	},
+	{
+		name:        "FIREFLY",
+		description: "Firefly III Data Importer CSV",
+		columns: []csvColumn{
+			{"Date", "Date"}, {"Description", "Merchant"}, {"Amount", "Amount"}, {"Asset account", "Account"},
+			{"Opposing account", "Opposing Account"}, {"Category", "Category"}, {"Tags", "Tags"}, {"Notes", "Notes"},
+		},
+		dateFormat:   "2006-01-02",
+		tagSeparator: ",",
+	},
+	{
+		name:        "LUNCHMONEY",
+		description: "Lunch Money CSV import",
+		columns: []csvColumn{
+			{"Date", "Date"}, {"Payee", "Merchant"}, {"Amount", "Amount"}, {"Category", "Category"},
+			{"Notes", "Notes"}, {"Tags", "Tags"},
+		},
+		dateFormat:   "2006-01-02",
+		tagSeparator: ",",
+	},
+	{
+		name:        "TILLER",
+		description: "Tiller Transactions sheet columns",
+		columns: []csvColumn{
+			{"Date", "Date"}, {"Description", "Merchant"}, {"Category", "Category"}, {"Amount", "Amount"},
+			{"Account", "Account"}, {"Check Number", "Check Number"}, {"Full Description", "Original Statement"},
+			{"Note", "Notes"}, {"Tags", "Tags"},
+		},
+		dateFormat:   "1/2/2006",
+		tagSeparator: ", ",
+	},
+}
+
+func init() {
+	for i := range csvPresets {
+		preset := &csvPresets[i]
+		registerFormat(exportFormat{
+			name:        preset.name,
+			description: preset.description,
+			preset:      preset,
+			new: func(s *exportSession, account exportAccount) Exporter {
+				return &presetExporter{preset: preset}
+			},
+		})
+	}
+}
+
+// presetExporter writes records as CSV rows in a preset's layout
+type presetExporter struct {
+	preset *csvPreset
+	w      io.Writer
+}
+
+func (e *presetExporter) Begin(w io.Writer, name string) error {
+	e.w = w
+	headers := make([]string, len(e.preset.columns))
+	for i, column := range e.preset.columns {
+		headers[i] = column.header
+	}
+	return writeHeader(w, strings.Join(headers, ",")+"\n")
+}
+
+func (e *presetExporter) WriteRecord(record TransactionRecord) error {
+	return writeTransaction(e.w, e.preset.row(record))
+}
+
+func (e *presetExporter) End() error { return nil }
+
+func (e *presetExporter) Ext() string { return ".csv" }
+
+// row builds the CSV row of a record
+func (p *csvPreset) row(record TransactionRecord) string {
+	tags := p.tags(record.Tags)
+	values := make([]string, len(p.columns))
+	for i, column := range p.columns {
+		switch column.field {
+		case "Date":
+			values[i] = record.Date
+			if date, err := time.Parse("2006-01-02", record.Date); err == nil {
+				values[i] = date.Format(p.dateFormat)
+			}
+		case "Inflow":
+			if !strings.HasPrefix(record.Amount, "-") {
+				values[i] = record.Amount
+			}
+		case "Outflow":
+			if amount, ok := strings.CutPrefix(record.Amount, "-"); ok {
+				values[i] = amount
+			}
+		case "Opposing Account":
+			values[i] = record.TransferAccount
+			if values[i] == "" {
+				values[i] = record.Merchant
+			}
+		case "Tags":
+			values[i] = tags
+		case "Notes":
+			values[i] = record.Notes
+			if p.tagsInNotes && tags != "" {
+				values[i] = strings.TrimSpace(values[i] + " " + tags)
+			}
+		default:
+			values[i] = recordField(record, column.field)
+		}
+	}
+	return quoteCSVRow(values)
+}
+
+// tags rewrites a comma-separated tag list with the preset's prefix and separator
+func (p *csvPreset) tags(list string) string {
+	var tags []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		if p.tagPrefix != "" {
+			// A hashtag ends at the first space
+			tag = strings.Join(strings.Fields(tag), "-")
+		}
+		tags = append(tags, p.tagPrefix+tag)
+	}
+	return strings.Join(tags, p.tagSeparator)
+}
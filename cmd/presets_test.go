@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"qifutil/test"
+)
+
+// exportPreset exports the Checking Account of transfers.qif with a preset
+// and returns the directory written to
+func exportPreset(helper *test.TestHelper, preset string) string {
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "transfers.qif")
+	helper.CopyTestData("transfers.qif", sourceFile)
+
+	selectedAccounts = "Checking Account"
+	startDate = ""
+	endDate = ""
+	outputFormat = preset
+	inputFile = sourceFile
+	outputPath = outputDir
+	tagForImport := addTagForImport
+	addTagForImport = true
+	defer func() { selectedAccounts = ""; outputFormat = "CSV"; addTagForImport = tagForImport }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	return outputDir
+}
+
+func TestYNABPreset(t *testing.T) {
+	helper := test.NewHelper(t)
+	checkingFile := filepath.Join(exportPreset(helper, "YNAB"), "Checking Account_1.csv")
+
+	// Amounts are split into positive Outflow and Inflow columns, dates are MM/DD/YYYY
+	helper.AssertFileContains(checkingFile, "Date,Payee,Memo,Outflow,Inflow\n")
+	helper.AssertFileContains(checkingFile, `"01/01/2023","Opening Balance","","","1000.00"`)
+	helper.AssertFileContains(checkingFile, `"01/12/2023","Grocery Store","","45.23",""`)
+}
+
+func TestActualPreset(t *testing.T) {
+	helper := test.NewHelper(t)
+	checkingFile := filepath.Join(exportPreset(helper, "actual"), "Checking Account_1.csv")
+
+	// Actual has no tag column; tags become hashtags in the notes
+	helper.AssertFileContains(checkingFile, "Date,Payee,Category,Notes,Amount\n")
+	helper.AssertFileContains(checkingFile, `"2023-01-12","Grocery Store","Food:Groceries","#QIFIMPORT","-45.23"`)
+}
+
+func TestFireflyPreset(t *testing.T) {
+	helper := test.NewHelper(t)
+	checkingFile := filepath.Join(exportPreset(helper, "FIREFLY"), "Checking Account_1.csv")
+
+	// Transfers name the other account as the opposing account, other
+	// transactions the payee
+	helper.AssertFileContains(checkingFile, `"2023-01-10","Transfer to savings","-500.00","Checking Account","Savings",`)
+	helper.AssertFileContains(checkingFile, `"2023-01-12","Grocery Store","-45.23","Checking Account","Grocery Store","Food:Groceries","QIFIMPORT",""`)
+}
+
+func TestTillerPreset(t *testing.T) {
+	helper := test.NewHelper(t)
+	checkingFile := filepath.Join(exportPreset(helper, "TILLER"), "Checking Account_1.csv")
+
+	helper.AssertFileContains(checkingFile, "Date,Description,Category,Amount,Account,Check Number,Full Description,Note,Tags\n")
+	helper.AssertFileContains(checkingFile, `"1/12/2023","Grocery Store","Food:Groceries","-45.23","Checking Account","","Grocery Store","","QIFIMPORT"`)
+}
+
+func TestPresetRecordLimit(t *testing.T) {
+	helper := test.NewHelper(t)
+
+	// Without --recordsPerFile, the preset's limit applies: Lunch Money
+	// gets every record in one file
+	maxRecordsPerFile = 1
+	defer func() { maxRecordsPerFile = 5000 }()
+	outputDir := exportPreset(helper, "LUNCHMONEY")
+
+	helper.AssertFileContains(filepath.Join(outputDir, "Checking Account_1.csv"), "Date,Payee,Amount,Category,Notes,Tags\n")
+	helper.AssertFileContains(filepath.Join(outputDir, "Checking Account_1.csv"), `"2023-01-20","Card payment","-200.00","Transfer","","QIFIMPORT"`)
+	if _, err := os.Stat(filepath.Join(outputDir, "Checking Account_2.csv")); err == nil {
+		t.Error("Expected one file with no record limit")
+	}
+}
+
+func TestPresetTags(t *testing.T) {
+	actual, _ := lookupFormat("ACTUAL")
+	if got := actual.preset.tags("QIFIMPORT, Home Improvement"); got != "#QIFIMPORT #Home-Improvement" {
+		t.Errorf("Actual tags = %q", got)
+	}
+	tiller, _ := lookupFormat("TILLER")
+	if got := tiller.preset.tags("QIFIMPORT,Vacation"); got != "QIFIMPORT, Vacation" {
+		t.Errorf("Tiller tags = %q", got)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright © 2025 Chris Gelhaus <chrisgelhaus@live.com>
+*/
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"qifutil/pkg/mapping"
+	"qifutil/pkg/money"
+	"qifutil/pkg/qif"
+	"qifutil/pkg/utils"
+)
+
+func init() {
+	registerFormat(exportFormat{
+		name:              "QIF",
+		description:       "Cleaned QIF file with splits, categories and the account header",
+		wholeTransactions: true,
+		noImportTag:       true,
+		new: func(s *exportSession, account exportAccount) Exporter {
+			return &qifExporter{account: account, lists: s.lists}
+		},
+		start: func(s *exportSession) error {
+			// Every file carries the lists, wherever they are in the source
+			var err error
+			s.lists, err = readQIFLists(s.inputFile, s.categoryMapping, s.tagMapping)
+			return err
+		},
+	})
+}
+
+// qifLists are the category, class and tag lists of a QIF file, with the
+// category and tag mappings applied
+type qifLists struct {
+	categories []qif.Category
+	classes    []qif.Class
+	tags       []qif.Tag
+}
+
+// readQIFLists reads the lists of a QIF file. A list entry mapped to a name
+// already listed is left out, so the first entry's income flag and
+// description are kept.
+func readQIFLists(path string, categoryMapping, tagMapping *mapping.Mapping) (*qifLists, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lists := &qifLists{}
+	listed := make(map[string]bool)
+	reader := qif.NewReader(file, qifOptions())
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return lists, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case entry.Category != nil:
+			category := *entry.Category
+			category.Name = listName(category.Name, categoryMapping)
+			if !listed["category:"+category.Name] {
+				listed["category:"+category.Name] = true
+				lists.categories = append(lists.categories, category)
+			}
+		case entry.Class != nil:
+			lists.classes = append(lists.classes, *entry.Class)
+		case entry.Tag != nil:
+			tag := *entry.Tag
+			tag.Name = listName(tag.Name, tagMapping)
+			if !listed["tag:"+tag.Name] {
+				listed["tag:"+tag.Name] = true
+				lists.tags = append(lists.tags, tag)
+			}
+		}
+	}
+}
+
+// qifExporter writes records as a QIF register
+type qifExporter struct {
+	account exportAccount
+	lists   *qifLists
+	writer  *qif.Writer
+}
+
+func (e *qifExporter) Begin(w io.Writer, name string) error {
+	// Each file starts with the lists and the account's header so it
+	// imports into the right account with its categories intact
+	e.writer = qif.NewWriter(w, qifOptions().Date)
+	e.writer.WriteCategories(e.lists.categories)
+	e.writer.WriteClasses(e.lists.classes)
+	e.writer.WriteTags(e.lists.tags)
+	account := &qif.Account{Name: e.account.outputName, Type: e.account.accountType}
+	// The stated balance only holds for the whole register, so a date
+	// filter leaves it out
+	if source := e.account.source; source != nil && startDate == "" && endDate == "" {
+		if balance, err := money.Parse(source.Balance); err == nil {
+			account.Balance = balance.String()
+			account.BalanceDate = source.BalanceDate
+		}
+	}
+	return e.writer.WriteAccount(account)
+}
+
+func (e *qifExporter) WriteRecord(record TransactionRecord) error {
+	return e.writer.WriteTransaction(record.transaction)
+}
+
+func (e *qifExporter) End() error {
+	return e.writer.Flush()
+}
+
+func (e *qifExporter) Ext() string { return ".qif" }
+
+// listName maps a name read ahead of the export, such as a list entry.
+// Rules aren't counted as used for it, so a rule that only matches the
+// list is still reported as unused, and nothing is printed.
+func listName(name string, m *mapping.Mapping) string {
+	if rule := m.Match(name); rule != nil {
+		return rule.Target
+	}
+	return name
+}
+
+// qifTransaction builds the transaction the QIF format writes for a record:
+// the record's payee, notes, amount and category, with the source
+// transaction's number, cleared status, address and mapped splits
+func qifTransaction(t *qif.Transaction, record TransactionRecord, category string, splits []qif.Split) *qif.Transaction {
+	return &qif.Transaction{
+		Date:     t.Date,
+		Amount:   record.Amount,
+		Cleared:  t.Cleared,
+		Number:   t.Number,
+		Payee:    record.Merchant,
+		Address:  t.Address,
+		Memo:     record.Notes,
+		Category: qifCategory(category, record.Tags),
+		Splits:   splits,
+	}
+}
+
+// qifSplits applies the category, tag and account mappings to the split
+// lines of a transaction for the QIF format and the Splits column
+func qifSplits(validator *utils.ValidationTracker, splits []qif.Split, categoryMapping, tagMapping, accountMapping *mapping.Mapping) []qif.Split {
+	if len(splits) == 0 {
+		return nil
+	}
+	mapped := make([]qif.Split, len(splits))
+	for i, split := range splits {
+		category, tag := utils.SplitCategoryAndTag(split.Category)
+		if counterpart, ok := qif.TransferAccount(category); ok {
+			category = "[" + applyMapping(counterpart, accountMapping) + "]"
+		} else {
+			category = applyTrackedMapping(validator, "category", category, categoryMapping)
+		}
+		tag = applyTrackedMapping(validator, "tag", tag, tagMapping)
+
+		amount := split.Amount
+		if value, err := money.Parse(amount); err == nil {
+			amount = value.String()
+		}
+		mapped[i] = qif.Split{Category: qifCategory(category, tag), Memo: split.Memo, Amount: amount, Percent: split.Percent}
+	}
+	return mapped
+}
+
+// qifCategory writes a category and comma-separated tags as a QIF L value,
+// Category/Tag, with several tags joined by colons as Quicken writes them
+func qifCategory(category, tags string) string {
+	var names []string
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			names = append(names, tag)
+		}
+	}
+	if len(names) == 0 {
+		return category
+	}
+	return category + "/" + strings.Join(names, ":")
+}
@@ -5,6 +5,10 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
+
+	"qifutil/pkg/qif"
 
 	"github.com/spf13/cobra"
 )
@@ -15,6 +19,10 @@ var inputFile string
 
 // var outputFile string
 var outputFormat string
+var pivotYear int
+var dayFirst bool
+var defaultAccountName string
+var inputEncoding string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -45,4 +53,31 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&selectedAccounts, "accounts", "", "Comma-separated list of accounts to process")
 	rootCmd.PersistentFlags().StringVar(&startDate, "startDate", "", "Start date filter (YYYY-MM-DD)")
 	rootCmd.PersistentFlags().StringVar(&endDate, "endDate", "", "End date filter (YYYY-MM-DD)")
+	rootCmd.PersistentFlags().IntVar(&pivotYear, "pivotYear", qif.DefaultPivotYear, "First year of the 100-year window for two-digit QIF years (e.g. 1950 reads '94 as 1994 and '23 as 2023)")
+	rootCmd.PersistentFlags().BoolVar(&dayFirst, "dayFirst", false, "QIF dates are written DD/MM instead of MM/DD")
+	rootCmd.PersistentFlags().StringVar(&inputEncoding, "inputEncoding", qif.EncodingAuto, "Character encoding of the QIF file: auto, utf-8, windows-1252 or iso-8859-1. Output is always UTF-8.")
+	rootCmd.PersistentFlags().StringVar(&defaultAccountName, "accountName", "", "Account name for a QIF file with no !Account header (default: the input file name)")
+}
+
+// qifOptions returns the parser options selected by the shared flags
+func qifOptions() qif.Options {
+	return qif.Options{
+		Date: qif.DateOptions{
+			PivotYear: pivotYear,
+			DayFirst:  dayFirst,
+		},
+		Encoding:    inputEncoding,
+		AccountName: implicitAccountName(),
+	}
+}
+
+// implicitAccountName returns the name given to the register of a file
+// without an !Account header: --accountName, or else the input file name
+// without its extension
+func implicitAccountName() string {
+	if defaultAccountName != "" {
+		return defaultAccountName
+	}
+	base := filepath.Base(inputFile)
+	return strings.TrimSuffix(base, filepath.Ext(base))
 }
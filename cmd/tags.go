@@ -9,9 +9,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 
+	"qifutil/pkg/qif"
+	"qifutil/pkg/utils"
+
 	"github.com/spf13/cobra"
 )
 
@@ -35,10 +37,6 @@ var tagsCmd = &cobra.Command{
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		var tags []string
-		var transactionRegexString string = `D(?<month>\d{1,2})\/(\s?(?<day>\d{1,2}))'(?<year>\d{2})[\r\n]+(U(?<amount1>.*?)[\r\n]+)(T(?<amount2>.*?)[\r\n]+)(C(?<cleared>.*?)[\r\n]+)((N(?<number>.*?)[\r\n]+)?)(P(?<payee>.*?)[\r\n]+)((M(?<memo>.*?)[\r\n]+)?)(L(?<category>.*?)[\r\n]+)`
-		var tagRecordRegex string = `(?m)(^N(.*)\n^(D(.*)\n^)?\^\n)`
-		var tagBlockHeaderRegex string = `(?m)^!Type:Tag\n`
-		var accountBlockHeaderRegex string = `(?m)^!Account[^\n]*\n^N(.*?)\n^T(.*?)\n^\^\n^!Type:(Bank|CCard)\s*\n`
 
 		// Build output file path using outputPath if provided
 		outputFilePath := tagsOutputFile
@@ -55,115 +53,40 @@ var tagsCmd = &cobra.Command{
 		}
 		defer tagFile.Close()
 
-		// Load input file
-		inputBytes, err := os.ReadFile(inputFile)
+		// Parse the input file
+		qifFile, err := qif.ParseFile(inputFile, qifOptions())
 		if err != nil {
 			fmt.Println("Error reading file:", err)
-		} else {
-			fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
+			return
 		}
-		inputContent := string(inputBytes)
-
-		// Standardize Line Endings to simplify Regex
-		inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
 
-		// Find the position of the Tag Block
-		tagTypeRe, err := regexp.Compile(tagBlockHeaderRegex)
-		if err != nil {
-			fmt.Println("Error compiling regular expression: ", err)
-		}
-		loc := tagTypeRe.FindStringIndex(inputContent)
-		if loc == nil {
+		// Gather tags from the Tag list
+		if len(qifFile.Tags) == 0 {
 			fmt.Printf("No Tag block found.\n")
-		} else {
-			// Debugging output
-			fmt.Printf("Tag block found at position: %d\n", loc[1])
 		}
-
-		// Find the position of the next Type block
-		restOfText := inputContent[loc[1]:]
-		nextTypePattern := `(?mi)^\s*!Type:.*$`
-		nextTypeRe := regexp.MustCompile(nextTypePattern)
-		nextLoc := nextTypeRe.FindStringIndex(restOfText)
-		var endPos int
-		if nextLoc != nil {
-			fmt.Printf("Next type found at:%d\n", nextLoc[1])
-			// Found another Type line.
-			endPos = loc[1] + nextLoc[0]
-		} else {
-			fmt.Printf("No next type block found.\n")
-			// No other Type found
-			endPos = len(inputContent)
-		}
-
-		// Extract the text between the Type lines
-		textBetweenTypes := inputContent[loc[1]:endPos]
-
-		// Use the existing pattern to match entries
-		regex, _ := regexp.Compile(tagRecordRegex)
-
-		// Find all matches in the content.
-		matches := regex.FindAllStringSubmatch(textBetweenTypes, -1)
-		fmt.Printf("%d entries extracted from the tag block.\n", len(matches))
-
-		// Extract patterns to array from the Tag block.
-		for _, t := range matches {
-			// Check if there is a captured group and extract the content.
-			if len(t) > 1 {
-				// Ensure there is a captured group.
-				tag := strings.TrimSpace(t[2])
-				if tag != "" {
-					tags = append(tags, tag)
-				}
+		fmt.Printf("%d entries extracted from the tag block.\n", len(qifFile.Tags))
+		for _, t := range qifFile.Tags {
+			if t.Name != "" {
+				tags = append(tags, t.Name)
 			}
 		}
 
-		// Gather categories from the Accounts
-		// Compile the regex
-		regex, _ = regexp.Compile(accountBlockHeaderRegex)
-
-		accountBlocks := regex.FindAllStringSubmatchIndex(inputContent, -1)
-		if len(accountBlocks) == 0 {
+		// Gather tags from the Accounts
+		if len(qifFile.Accounts) == 0 {
 			fmt.Println("No matches found.")
 		}
 
-		// loop over each account block and pull out tags
-		for _, accountBlock := range accountBlocks {
-			// Find the next Type Block
-			accountName := inputContent[accountBlock[2]:accountBlock[3]]
-
-			restOfText := inputContent[accountBlock[1]:]
-			nextTypePattern := `(?mi)^\s*!Type:.*$`
-			nextTypeRe := regexp.MustCompile(nextTypePattern)
-			nextLoc := nextTypeRe.FindStringIndex(restOfText)
-			var endPos int
-			if nextLoc != nil {
-				endPos = accountBlock[1] + nextLoc[0]
-			} else {
-				endPos = len(inputContent)
-			}
+		// loop over each account and pull out tags
+		for _, account := range qifFile.Accounts {
+			fmt.Printf("%d tags extracted from account: %s\n", len(account.Transactions), account.Name)
 
-			// Extract the text between the type lines
-			textBetweenTypes := inputContent[accountBlock[1]:endPos]
-
-			// Use the existing pattern to match entries
-			regex, _ := regexp.Compile(transactionRegexString)
-
-			// Find all matches in the content.
-			transactions := regex.FindAllStringSubmatch(textBetweenTypes, -1)
-			fmt.Printf("%d tags extracted from account: %s\n", len(transactions), accountName)
-
-			// Loop through matches and add categories to the array
-			for _, t := range transactions {
-				if len(t) > 1 {
-					_, tag := splitCategoryAndTag(t[20])
-					tag = strings.TrimSpace(tag)
-					if tag != "" {
-						// Remove double quotes
-						tag = strings.ReplaceAll(tag, "\"", "")
-						// Add tag to the list
-						tags = append(tags, tag)
-					}
+			for _, t := range account.Transactions {
+				_, tag := utils.SplitCategoryAndTag(t.Category)
+				if tag != "" {
+					// Remove double quotes
+					tag = strings.ReplaceAll(tag, "\"", "")
+					// Add tag to the list
+					tags = append(tags, tag)
 				}
 			}
 		}
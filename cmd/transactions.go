@@ -4,17 +4,17 @@ Copyright © 2025 Chris Gelhaus <chrisgelhaus@live.com>
 package cmd
 
 import (
-	"encoding/csv"
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
+	"qifutil/pkg/mapping"
+	"qifutil/pkg/money"
+	"qifutil/pkg/qif"
+	"qifutil/pkg/rules"
 	"qifutil/pkg/utils"
 
 	"github.com/spf13/cobra"
@@ -33,8 +33,20 @@ var addTagForImport bool = false
 var skipZeroAmounts bool = false
 var maxRecordsPerFile int = 5000
 var csvColumns string
-
-// Default columns for Monarch Money format
+var splitMode string
+var transferCategory string
+var transferTag string
+var matchTransfers bool
+var rulesFile string
+var dedupe bool
+var duplicatesAcrossAccounts bool
+
+// openingBalanceCategory is the category of a transfer to the account
+// itself, which is how Quicken records an opening balance. The QIF and
+// journal formats keep the bracketed account instead.
+const openingBalanceCategory = "Opening Balance"
+
+// Default --csvColumns: the columns of the MONARCH preset
 const DefaultMonarchColumns = "Date,Merchant,Category,Account,Original Statement,Notes,Amount,Tags"
 
 type TransactionRecord struct {
@@ -42,14 +54,21 @@ type TransactionRecord struct {
 	Merchant          string `json:"merchant" xml:"merchant"`
 	Category          string `json:"category" xml:"category"`
 	Account           string `json:"account" xml:"account"`
+	AccountType       string `json:"account_type" xml:"account_type"`
 	OriginalStatement string `json:"original_statement" xml:"original_statement"`
 	Notes             string `json:"notes" xml:"notes"`
 	Amount            string `json:"amount" xml:"amount"`
 	Tags              string `json:"tags" xml:"tags"`
-}
-type transactionList struct {
-	XMLName      xml.Name            `xml:"transactions"`
-	Transactions []TransactionRecord `xml:"transaction"`
+	CheckNumber       string `json:"check_number,omitempty" xml:"check_number,omitempty"`
+	Cleared           string `json:"cleared,omitempty" xml:"cleared,omitempty"`
+	Address           string `json:"address,omitempty" xml:"address,omitempty"`
+	SplitID           string `json:"split_id,omitempty" xml:"split_id,omitempty"`
+	Splits            string `json:"splits,omitempty" xml:"splits,omitempty"`
+	TransferAccount   string `json:"transfer_account,omitempty" xml:"transfer_account,omitempty"`
+	TransferID        string `json:"transfer_id,omitempty" xml:"transfer_id,omitempty"`
+
+	transaction *qif.Transaction // Transaction written by the QIF format, with mappings applied
+	source      *qif.Transaction // Transaction as read from the QIF file
 }
 
 // transactionsCmd represents the transactions command
@@ -90,7 +109,10 @@ TIPS:
 OPTIONS:
   --inputFile          Required. Path to the QIF file to process
   --outputPath         Required. Directory where CSV files will be created
-  --outputFormat       Optional. Output format: CSV, JSON, XML, or MONARCH (default: CSV)
+  --outputFormat       Optional. Output format: CSV, JSON, XML, MONARCH, YNAB,
+                       ACTUAL, FIREFLY, LUNCHMONEY, TILLER, OFX, OFX2, QIF,
+                       LEDGER, HLEDGER or BEANCOUNT (default: CSV).
+                       --outputFormat list prints the available formats
   --csvColumns         Optional. Comma-separated column names for CSV output
                        (only applies to CSV format). Default is Monarch format.
   --accounts           Optional. Comma-separated list of accounts to process
@@ -98,21 +120,66 @@ OPTIONS:
   --accountMapFile     Optional. CSV file mapping source to target account names
   --payeeMapFile       Optional. CSV file mapping source to target payee names
   --tagMapFile         Optional. CSV file mapping source to target tags
+  --rulesFile          Optional. YAML or JSON rules file; see RULES FILES below
   --maxRecordsPerFile  Optional. Maximum transactions per output file (default: 5000)
   --addTagForImport    Optional. Add QIFIMPORT tag to all transactions
+                       (not used by the QIF and journal formats)
+  --splitMode          Optional. COLUMN (default) writes one row per split
+                       transaction with its lines in the Splits column; ROWS
+                       writes one row per split line sharing a Split ID
+  --transferCategory   Optional. Category written for transfers between
+                       accounts, e.g. L[Savings] (default: Transfer)
+                       A transfer to the account itself is its opening
+                       balance and gets the category Opening Balance
+  --transferTag        Optional. Tag added to every transfer
+  --matchTransfers     Optional. Pair each transfer with its counterpart in
+                       the other account and report transfers with none
+  --dedupe             Optional. Leave out transactions identical to an
+                       earlier one in the same account
+  --duplicatesAcrossAccounts
+                       Optional. Report potential duplicates found in
+                       different accounts, not only within one account
 
 SUPPORTED FORMATS:
   CSV:     Generic CSV format. Column order is customizable via --csvColumns.
            Available columns: Date, Merchant, Category, Account,
-           Original Statement, Notes, Amount, Tags
+           Account Type, Original Statement, Notes, Amount, Tags,
+           Split ID, Splits, Transfer Account, Transfer ID,
+           Check Number, Cleared, Address
 
   MONARCH: Optimized for Monarch Money import. Equivalent to CSV format with
            all standard columns in the recommended order.
 
+  YNAB, ACTUAL, FIREFLY, LUNCHMONEY, TILLER:
+           CSV presets for YNAB, Actual Budget, Firefly III, Lunch Money and
+           Tiller. Each fixes the columns and their headers, the date
+           format, signed amounts or Outflow/Inflow columns, how tags are
+           written and the records per file. --csvColumns is not used;
+           --recordsPerFile overrides the preset's limit.
+
   JSON:    JSON array of transaction objects. One file per account.
 
   XML:     XML format with transaction elements. One file per account.
 
+  OFX:     OFX 1.x (SGML) bank or credit card statement, one per account,
+           for GnuCash, Moneydance, Banktivity and bank imports. A split
+           transaction is one entry; --splitMode is ignored.
+
+  OFX2:    The same statement as OFX 2.x (XML).
+
+  QIF:     A cleaned QIF file per account, with mappings, rules and filters
+           applied. Splits, the category, class and tag lists and the
+           account header are kept and transfers stay [Account] categories.
+           --splitMode and --addTagForImport are ignored.
+
+  LEDGER, HLEDGER, BEANCOUNT:
+           Plain-text accounting journals. Accounts become Assets: or
+           Liabilities: accounts and categories Expenses: or Income:
+           accounts. Each transfer is one entry with a posting to each
+           account. main.ledger, main.journal or main.beancount declares
+           every account and includes the account files.
+           --addTagForImport is ignored.
+
 EXAMPLE COLUMNS:
   --csvColumns "Date,Merchant,Amount"
   --csvColumns "Date,Merchant,Category,Account,Amount"
@@ -134,13 +201,38 @@ MAPPING FILES:
   
   Example category mapping:
   "Groceries","Food:Groceries"
-  "Gas","Transportation:Fuel"`,
+  "Gas","Transportation:Fuel"
+
+RULES FILES:
+  A rules file lists conditions and actions, evaluated in order for every
+  transaction. Conditions test the values in the QIF file (payee, memo,
+  account, category, amount, date); actions set the category, rename the
+  payee, add tags, append notes, or drop the transaction.
+
+  rules:
+    - name: Fuel
+      when:
+        payee: {contains: SHELL}
+        amount: {max: -20}
+      then:
+        category: Auto:Fuel
+        addTags: [Car]`,
 	PreRun: func(cmd *cobra.Command, args []string) {
+		// --outputFormat list needs no other flags
+		if strings.EqualFold(outputFormat, "list") {
+			return
+		}
+
 		if inputFile == "" {
 			fmt.Println("Error: Missing required flag --inputFile")
 			os.Exit(1)
 		}
 
+		if _, ok := lookupFormat(outputFormat); !ok {
+			fmt.Printf("Error: Unknown --outputFormat %s. Use one of %s, or --outputFormat list\n", outputFormat, strings.Join(formatNames(), ", "))
+			os.Exit(1)
+		}
+
 		// Validate selected accounts format if provided
 		if selectedAccounts != "" {
 			accounts := strings.Split(selectedAccounts, ",")
@@ -152,6 +244,12 @@ MAPPING FILES:
 			}
 		}
 
+		// Validate split mode
+		if strings.ToUpper(splitMode) != "ROWS" && strings.ToUpper(splitMode) != "COLUMN" {
+			fmt.Println("Error: Invalid --splitMode. Use ROWS or COLUMN")
+			os.Exit(1)
+		}
+
 		// Validate date format if provided
 		dateFormat := "2006-01-02"
 		if startDate != "" {
@@ -177,6 +275,16 @@ MAPPING FILES:
 		}
 	},
 	Run: func(cmd *cobra.Command, args []string) {
+		if strings.EqualFold(outputFormat, "list") {
+			printFormats()
+			return
+		}
+		format, ok := lookupFormat(outputFormat)
+		if !ok {
+			fmt.Printf("Error: Unknown --outputFormat %s\n", outputFormat)
+			return
+		}
+
 		fmt.Println("Starting transaction export...")
 
 		// Ensure we have a valid output path
@@ -231,426 +339,395 @@ MAPPING FILES:
 			}
 		}
 
-		// Output CSV Header
-		var transactionRegexString string = `D(?<month>\d{1,2})\/(\s?(?<day>\d{1,2}))'(?<year>\d{2})[\r\n]+(U(?<amount1>.*?)[\r\n]+)(T(?<amount2>.*?)[\r\n]+)(C(?<cleared>.*?)[\r\n]+)((N(?<number>.*?)[\r\n]+)?)((P(?<payee>.*?)[\r\n]+)?)((M(?<memo>.*?)[\r\n]+)?)(L(?<category>.*?)[\r\n]+)`
-		var accountBlockHeaderRegex string = `(?m)^!Account[^\n]*\n^N(.*?)\n^T(.*?)\n^\^\n^!Type:(Bank|CCard)\s*\n`
-
-		// If MONARCH format is specified, use the default columns
-		columnsToUse := csvColumns
-		if strings.ToUpper(outputFormat) == "MONARCH" {
-			columnsToUse = DefaultMonarchColumns
-			outputFormat = "CSV" // Internally treat MONARCH as CSV
-		}
-
-		var outputCSVHeader string = columnsToUse + "\n"
-		var categoryMapping map[string]string
-		var payeeMapping map[string]string
-		var accountMapping map[string]string
-		var tagMapping map[string]string
 		var err error
 
-		// Load the Category Mapping
-		if categoryMappingFile != "" {
-			categoryMapping, err = loadMapping(categoryMappingFile)
-			if err != nil {
-				fmt.Println("Error loading category mapping:", err)
-				return
-			}
-			fmt.Printf("%d Category Mappings Loaded:\n", len(categoryMapping))
-			for k, v := range categoryMapping {
-				fmt.Printf("  %s -> %s\n", k, v)
-			}
-		} else {
-			fmt.Println("No category mapping file specified.")
+		// Load the mapping files
+		categoryMapping, err := loadMapping("Category", categoryMappingFile)
+		if err != nil {
+			return
+		}
+		payeeMapping, err := loadMapping("Payee", payeeMappingFile)
+		if err != nil {
+			return
+		}
+		accountMapping, err := loadMapping("Account", accountMappingFile)
+		if err != nil {
+			return
+		}
+		tagMapping, err := loadMapping("Tag", tagMappingFile)
+		if err != nil {
+			return
 		}
 
-		// Load the Payee Mapping
-		if payeeMappingFile != "" {
-			payeeMapping, err = loadMapping(payeeMappingFile)
+		// Load the rules file
+		var ruleSet *rules.Set
+		if rulesFile != "" {
+			ruleSet, err = rules.Load(rulesFile)
 			if err != nil {
-				fmt.Println("Error loading payee mapping:", err)
+				fmt.Println("Error loading rules:", err)
 				return
 			}
-			fmt.Printf("%d Payee Mappings Loaded:\n", len(payeeMapping))
-			for k, v := range payeeMapping {
-				fmt.Printf("  %s -> %s\n", k, v)
-			}
-		} else {
-			fmt.Println("No payee mapping file specified.")
+			fmt.Printf("%d Rules Loaded\n", len(ruleSet.Rules))
 		}
-
-		// Load the Account Mapping
-		if accountMappingFile != "" {
-			accountMapping, err = loadMapping(accountMappingFile)
-			if err != nil {
-				fmt.Println("Error loading account mapping:", err)
+		droppedByRules := 0
+
+		session := &exportSession{
+			format:          format,
+			inputFile:       inputFile,
+			outputPath:      outputPath,
+			columns:         csvColumns,
+			maxRecords:      maxRecordsPerFile,
+			accountMapping:  accountMapping,
+			categoryMapping: categoryMapping,
+			tagMapping:      tagMapping,
+			transfers:       make(map[string]bool),
+		}
+		// A preset's record limit applies unless --recordsPerFile is given
+		if format.preset != nil && !cmd.Flags().Changed("recordsPerFile") {
+			session.maxRecords = format.preset.maxRecordsPerFile
+		}
+		if format.start != nil {
+			if err := format.start(session); err != nil {
+				fmt.Println("Error reading file:", err)
 				return
 			}
-			fmt.Printf("%d Account Mappings Loaded:\n", len(accountMapping))
-			for k, v := range accountMapping {
-				if v != "" {
-					fmt.Printf("  %s -> %s\n", k, v)
-				}
-			}
-		} else {
-			fmt.Println("No account mapping file specified.")
 		}
 
-		// Load the Tag Mapping
-		if tagMappingFile != "" {
-			tagMapping, err = loadMapping(tagMappingFile)
+		// Pair transfers up front so both halves can carry the same Transfer
+		// ID, and so formats that write a transfer once can find its pair
+		var transfers *qif.TransferMatcher
+		if matchTransfers || format.pairTransfers {
+			transfers, err = scanTransfers(inputFile)
 			if err != nil {
-				fmt.Println("Error loading tag mapping:", err)
+				fmt.Println("Error reading file:", err)
 				return
 			}
-			fmt.Printf("%d Tag Mappings Loaded:\n", len(tagMapping))
-			for k, v := range tagMapping {
-				fmt.Printf("  %s -> %s\n", k, v)
-			}
-		} else {
-			fmt.Println("No tag mapping file specified.")
 		}
 
-		// Open the input file and find all the Bank and CCard blocks
-		// Load input file
-		inputBytes, err := os.ReadFile(inputFile)
+		// Open the input file; transactions are read and written one at a
+		// time so memory use doesn't grow with the size of the file
+		input, err := os.Open(inputFile)
 		if err != nil {
 			fmt.Println("Error reading file:", err)
-		} else {
-			fmt.Printf("Input file opened. Length: %d\n", len(inputBytes))
-		}
-		inputContent := string(inputBytes)
-
-		// Standardize Line Endings to simplify Regex
-		inputContent = strings.ReplaceAll(inputContent, "\r\n", "\n")
-
-		// Gather the Account Blocks
-		// Compile the regex
-		regex, err := regexp.Compile(accountBlockHeaderRegex)
-		if err != nil {
 			return
 		}
-		accountBlocks := regex.FindAllStringSubmatchIndex(inputContent, -1)
-		if len(accountBlocks) == 0 {
-			fmt.Println("No matches found.")
-		}
+		defer input.Close()
 
 		// Initialize validation tracker for all accounts
 		validator := utils.NewValidationTracker()
 
-		// loop over each account block and Find all transaction matches
-		for _, accountBlock := range accountBlocks {
-			// Extract the account name from the matched block
-			accountName := inputContent[accountBlock[2]:accountBlock[3]]
+		// Group transactions on date, amount and payee to find repeated downloads
+		duplicates := qif.NewDuplicateDetector(duplicatesAcrossAccounts)
 
-			// If specific accounts are selected, skip accounts that aren't in the list
-			if len(selectedAccountList) > 0 {
-				accountFound := false
-				for _, selectedAccount := range selectedAccountList {
-					if accountName == selectedAccount {
-						accountFound = true
-						break
-					}
+		// Sum every account's transactions to compare with its stated balance
+		reconciler := qif.NewReconciler()
+
+		// Report transfers in the selected accounts and dates that have no counterpart
+		if matchTransfers {
+			for _, side := range transfers.Unmatched() {
+				if len(selectedAccountList) > 0 && !containsString(selectedAccountList, side.Account) {
+					continue
 				}
-				if !accountFound {
+				if !inDateRange(side.Date) {
 					continue
 				}
+				validator.AddUnmatchedTransfer(side.Date.Format("2006-01-02"), side.Account, side.Counterpart, side.Amount.String())
 			}
+		}
 
-			// Map the account name using the account mapping if available
-			var outputAccountName string
-			if len(accountMapping[accountName]) > 0 {
-				outputAccountName = accountMapping[accountName]
-			} else {
-				outputAccountName = accountName
-			}
-
-			restOfText := inputContent[accountBlock[1]:]
-			nextTypePattern := `(?mi)^\s*!Type:.*$`
-			nextTypeRe := regexp.MustCompile(nextTypePattern)
-			nextLoc := nextTypeRe.FindStringIndex(restOfText)
-			var endPos int
-			if nextLoc != nil {
-				endPos = accountBlock[1] + nextLoc[0]
-			} else {
-				endPos = len(inputContent)
+		// Open exports by account name; an account's register can appear
+		// more than once in a file, so they stay open until the end
+		exports := make(map[string]*accountExport)
+		var exportOrder []*accountExport
+		defer func() {
+			for _, exp := range exportOrder {
+				exp.close()
 			}
+		}()
+		accountsFound := 0
 
-			fileIndex := 1
-			count := 0
-			var records []TransactionRecord
-
-			// Determine file extension based on output format
-			ext := ".csv"
-			switch strings.ToUpper(outputFormat) {
-			case "JSON":
-				ext = ".json"
-			case "XML":
-				ext = ".xml"
+		reader := qif.NewReader(input, qifOptions())
+		for {
+			entry, err := reader.Next()
+			if err == io.EOF {
+				break
 			}
-
-			// Create unique output file per Account
-			outputFileName := fmt.Sprintf("%s_%d%s", accountName, fileIndex, ext)
-			fmt.Printf("\nProcessing %s (File %d)\n", accountName, fileIndex)
-
-			fullPath := filepath.Join(outputPath, outputFileName)
-			outputFile, err := os.Create(fullPath)
 			if err != nil {
-				fmt.Printf("Error creating file %s: %v\n", outputFileName, err)
+				fmt.Println("Error reading file:", err)
 				return
 			}
-			if strings.ToUpper(outputFormat) == "XML" {
-				_, err := outputFile.WriteString(xml.Header)
-				if err != nil {
-					outputFile.Close()
-					fmt.Printf("Error: failed to write XML header to %s: %v\n", outputFileName, err)
-					return
+			if entry.Account == nil {
+				continue
+			}
+			account := entry.Account
+			accountName := account.Name
+
+			// If specific accounts are selected, skip accounts that aren't in the list
+			if len(selectedAccountList) > 0 && !containsString(selectedAccountList, accountName) {
+				continue
+			}
+
+			// Investment registers have their own export
+			if strings.EqualFold(account.Type, "Invst") {
+				if entry.Transaction == nil && entry.Investment == nil {
+					fmt.Printf("\nSkipping investment account %s (use 'qifutil export investments')\n", accountName)
 				}
+				continue
 			}
-			if strings.ToUpper(outputFormat) == "CSV" {
-				if err := writeHeader(outputFile, outputCSVHeader); err != nil {
-					outputFile.Close()
-					fmt.Printf("Error: failed to write header to %s: %v\n", outputFileName, err)
+
+			exp := exports[accountName]
+			if exp == nil {
+				accountsFound++
+
+				// Map the account name using the account mapping if available
+				outputAccountName := applyMapping(accountName, accountMapping)
+
+				exp = &accountExport{exportAccount: exportAccount{name: accountName, outputName: outputAccountName, accountType: account.Type, source: account}, maxRecords: session.maxRecords}
+				exp.exporter = format.new(session, exp.exportAccount)
+				if err := exp.open(); err != nil {
+					fmt.Printf("Error: %v\n", err)
 					return
 				}
+				exports[accountName] = exp
+				exportOrder = append(exportOrder, exp)
 			}
 
-			// Extract the text between the type lines
-			textBetweenTypes := inputContent[accountBlock[1]:endPos]
+			t := entry.Transaction
+			if t == nil {
+				continue
+			}
+			exp.transactions++
+			reconciler.Add(account, t)
 
-			// Use the existing pattern to match entries
-			regex, err := regexp.Compile(transactionRegexString)
-			if err != nil {
-				return
+			// Check if the transaction date is within the specified range
+			if !inDateRange(t.Date) {
+				continue
 			}
 
-			// Find all transactions in the content.
-			transactions := regex.FindAllStringSubmatch(textBetweenTypes, -1)
+			// Leave out exact copies of a transaction already written
+			if duplicates.Add(accountName, t) && dedupe {
+				validator.AddRemovedDuplicate()
+				continue
+			}
 
-			// Print the number of transactions found
-			fmt.Printf("Number of transactions found: %d\n", len(transactions))
+			// DATE FORMAT: YYYY-MM-DD
+			fullDate := t.Date.Format("2006-01-02")
 
-			// Create a helper to extract named groups from regex matches
-			getGroup := func(match []string, name string) string {
-				for i, subexp := range regex.SubexpNames() {
-					if subexp == name && i < len(match) {
-						return match[i]
-					}
-				}
-				return ""
+			// Apply the payee mapping
+			payee := applyTrackedMapping(validator, "payee", t.Payee, payeeMapping)
+			// Remove double quotes
+			payee = strings.ReplaceAll(payee, "\"", "")
+
+			// A split transaction is written as one parent row, or as
+			// one row per split line when --splitMode=ROWS
+			lines := []qif.Split{{Category: t.Category, Memo: t.Memo, Amount: t.Amount}}
+			if strings.ToUpper(splitMode) == "ROWS" && len(t.Splits) > 0 && !format.wholeTransactions {
+				lines = t.Splits
 			}
 
-			for _, t := range transactions {
-				if len(t) > 1 {
-					month := strings.TrimSpace(getGroup(t, "month"))
-					day := strings.TrimSpace(getGroup(t, "day"))
-					year := strings.TrimSpace(getGroup(t, "year"))
-					amount1 := strings.TrimSpace(getGroup(t, "amount1"))
-					// Remove commas from amount for compatibility (e.g., "1,234.56" -> "1234.56")
-					amount1 = strings.ReplaceAll(amount1, ",", "")
-					// Parse amount to float and format with exactly 2 decimal places
-					amountFloat, err := strconv.ParseFloat(amount1, 64)
-					if err != nil {
-						fmt.Printf("Warning: Could not parse amount '%s', using as-is\n", amount1)
-					} else {
-						amount1 = fmt.Sprintf("%.2f", amountFloat)
-					}
+			for _, line := range lines {
+				// Remove commas from amount for compatibility (e.g., "1,234.56" -> "1234.56")
+				amount1 := strings.ReplaceAll(line.Amount, ",", "")
+				// Parse amount and format with exactly 2 decimal places
+				amountValue, err := money.Parse(amount1)
+				if err != nil {
+					fmt.Printf("Warning: Could not parse amount '%s', using as-is\n", amount1)
+				} else {
+					amount1 = amountValue.String()
+				}
 
-					payee := strings.TrimSpace(getGroup(t, "payee"))
-					// Apply the payee mapping
-					payee = applyMapping(payee, payeeMapping)
-					// Remove double quotes
-					payee = strings.ReplaceAll(payee, "\"", "")
+				// Split lines without a memo of their own keep the transaction memo
+				notes := line.Memo
+				if notes == "" {
+					notes = t.Memo
+				}
 
-					transactionMemo := strings.TrimSpace(getGroup(t, "memo"))
+				// Split the category and tag
+				category, tag := utils.SplitCategoryAndTag(line.Category)
+
+				// Evaluate the rules against the transaction as written in the QIF file
+				ruleResult := ruleSet.Apply(rules.Transaction{
+					Date:     t.Date,
+					Payee:    t.Payee,
+					Memo:     notes,
+					Account:  accountName,
+					Category: category,
+					Amount:   amountValue,
+				})
+				if ruleResult.Drop {
+					droppedByRules++
+					continue
+				}
 
-					// Split the category and tag
-					categoryRaw := strings.TrimSpace(getGroup(t, "category"))
-					category, tag := utils.SplitCategoryAndTag(categoryRaw)
+				// Transfers name the other account in brackets, e.g. [Savings]
+				// A transfer to the account itself is Quicken's opening balance
+				counterpart, bracketed := qif.TransferAccount(category)
+				isTransfer := bracketed && counterpart != accountName
+				openingBalance := bracketed && !isTransfer
+				if openingBalance {
+					category = openingBalanceCategory
+				}
+				if isTransfer && transferCategory != "" {
+					category = transferCategory
+				}
+				if isTransfer && transferTag != "" {
+					if tag != "" {
+						tag = transferTag + "," + tag
+					} else {
+						tag = transferTag
+					}
+				}
 
-					// Trim whitespace
-					category = strings.TrimSpace(category)
-					// Apply the category mapping
+				// Apply the category mapping. The categories given to transfers
+				// and opening balances aren't values from the file, so they
+				// aren't reported as unmapped.
+				if isTransfer || openingBalance {
 					category = applyMapping(category, categoryMapping)
+				} else {
+					category = applyTrackedMapping(validator, "category", category, categoryMapping)
+				}
 
-					// Trim whitespace
-					tag = strings.TrimSpace(tag)
-					// Apply the tag mapping
-					tag = applyMapping(tag, tagMapping)
-
-					// Prepend a custom Tag to the Category
-					if addTagForImport {
-						if tag != "" {
-							tag = "QIFIMPORT," + tag
-						} else {
-							tag = "QIFIMPORT"
-						}
-					}
+				// Apply the tag mapping
+				tag = applyTrackedMapping(validator, "tag", tag, tagMapping)
 
-					// DATE FORMAT: YYYY-MM-DD
-					fullYear := "20" + year
-					month = "0" + month
-					fullMonth := month[len(month)-2:]
-					day = "0" + day
-					fullDay := day[len(day)-2:]
-					fullDate := fullYear + "-" + fullMonth + "-" + fullDay
-
-					// Check if the transaction date is within the specified range
-					transDate, _ := time.Parse("2006-01-02", fullDate)
-					if startDate != "" {
-						startDateTime, _ := time.Parse("2006-01-02", startDate)
-						if transDate.Before(startDateTime) {
-							continue
-						}
-					}
-					if endDate != "" {
-						endDateTime, _ := time.Parse("2006-01-02", endDate)
-						if transDate.After(endDateTime) {
-							continue
-						}
-					}
-
-					// Validation tracking
-					validator.RecordTransaction()
-					if payee == "" {
-						validator.AddMissingPayee()
-					}
-					if category == "" {
-						validator.AddMissingCategory()
+				// Rule actions take precedence over the mapping files
+				merchant := payee
+				if ruleResult.SetPayee {
+					merchant = ruleResult.Payee
+				}
+				if ruleResult.SetCategory {
+					category = ruleResult.Category
+				}
+				for _, ruleTag := range ruleResult.Tags {
+					if tag != "" {
+						tag += "," + ruleTag
+					} else {
+						tag = ruleTag
 					}
-					if amount1 == "0.00" || amount1 == "0" {
-						validator.AddZeroAmount()
-						validator.RecordTransactionIssue(fullDate, payee, amount1, category, "ZeroAmount")
-						// Skip this transaction if the skipZeroAmounts flag is set
-						if skipZeroAmounts {
-							validator.AddSkippedZeroAmount()
-							continue
-						}
+				}
+				for _, note := range ruleResult.Notes {
+					if notes != "" {
+						notes += " " + note
+					} else {
+						notes = note
 					}
+				}
 
-					record := TransactionRecord{
-						Date:              fullDate,
-						Merchant:          payee,
-						Category:          category,
-						Account:           outputAccountName,
-						OriginalStatement: payee,
-						Notes:             transactionMemo,
-						Amount:            amount1,
-						Tags:              tag,
+				// Prepend a custom Tag to the Category
+				if addTagForImport && !format.noImportTag {
+					if tag != "" {
+						tag = "QIFIMPORT," + tag
+					} else {
+						tag = "QIFIMPORT"
 					}
+				}
 
-					if strings.ToUpper(outputFormat) == "JSON" {
-						records = append(records, record)
-					} else {
-						line := buildCSVRow(record, columnsToUse)
-						if err := writeTransaction(outputFile, line); err != nil {
-							outputFile.Close()
-							fmt.Printf("failed to write transaction: %v\n", err)
-							return
-						}
+				// Validation tracking
+				validator.RecordTransaction()
+				if merchant == "" {
+					validator.AddMissingPayee()
+				}
+				if category == "" {
+					validator.AddMissingCategory()
+				}
+				if err == nil && amountValue == 0 {
+					validator.AddZeroAmount()
+					validator.RecordTransactionIssue(fullDate, merchant, amount1, category, "ZeroAmount")
+					// Skip this transaction if the skipZeroAmounts flag is set
+					if skipZeroAmounts {
+						validator.AddSkippedZeroAmount()
+						continue
 					}
-					count++
-					// Check if we need to split the file
-					if maxRecordsPerFile != 0 && count%maxRecordsPerFile == 0 {
-						// Close current file
-						if strings.ToUpper(outputFormat) == "JSON" {
-							jsonData, err := json.MarshalIndent(records, "", "  ")
-							if err != nil {
-								outputFile.Close()
-								fmt.Printf("Error: failed to marshal JSON data: %v\n", err)
-								return
-							}
-							_, err = outputFile.Write(jsonData)
-							if err != nil {
-								outputFile.Close()
-								fmt.Printf("Error: failed to write JSON data to file: %v\n", err)
-								return
-							}
-							records = nil
-						} else if strings.ToUpper(outputFormat) == "XML" {
-							xmlData, err := xml.MarshalIndent(transactionList{Transactions: records}, "", "  ")
-							if err != nil {
-								outputFile.Close()
-								fmt.Printf("Error: failed to marshal XML data: %v\n", err)
-								return
-							}
-							_, err = outputFile.Write(xmlData)
-							if err != nil {
-								outputFile.Close()
-								fmt.Printf("Error: failed to write XML data to file: %v\n", err)
-								return
-							}
-							records = nil
-						}
-						outputFile.Close()
-
-						// Start new file
-						fileIndex++
-						outputFileName = fmt.Sprintf("%s_%d%s", accountName, fileIndex, ext)
-						fullPath := filepath.Join(outputPath, outputFileName)
-						fmt.Printf("\nCreating split file for %s (File %d) - Records %d to %d\n",
-							accountName,
-							fileIndex,
-							(fileIndex-1)*maxRecordsPerFile+1,
-							fileIndex*maxRecordsPerFile)
-
-						outputFile, err = os.Create(fullPath)
-						if err != nil {
-							fmt.Printf("Error creating split file %s: %v\n", outputFileName, err)
-							return
-						}
+				}
 
-						// Write appropriate headers for the new file
-						if strings.ToUpper(outputFormat) == "XML" {
-							_, err := outputFile.WriteString(xml.Header)
-							if err != nil {
-								outputFile.Close()
-								fmt.Printf("Error: failed to write XML header to split file %s: %v\n", outputFileName, err)
-								return
-							}
-						}
-						if strings.ToUpper(outputFormat) == "CSV" {
-							if err := writeHeader(outputFile, outputCSVHeader); err != nil {
-								outputFile.Close()
-								fmt.Printf("Error: failed to write header to %s: %v\n", outputFileName, err)
-								return
-							}
+				record := TransactionRecord{
+					Date:              fullDate,
+					Merchant:          merchant,
+					Category:          category,
+					Account:           exp.outputName,
+					AccountType:       exp.accountType,
+					OriginalStatement: payee,
+					Notes:             notes,
+					Amount:            amount1,
+					Tags:              tag,
+					CheckNumber:       t.Number,
+					Cleared:           t.Cleared,
+					Address:           strings.Join(t.Address, ", "),
+					source:            t,
+				}
+				if isTransfer {
+					record.TransferAccount = applyMapping(counterpart, accountMapping)
+					if transfers != nil && len(t.Splits) == 0 {
+						if pair, ok := transfers.Pair(accountName, exp.transactions); ok {
+							record.TransferID = fmt.Sprintf("T%d", pair)
 						}
 					}
-
 				}
-			}
-			if strings.ToUpper(outputFormat) == "JSON" && len(records) > 0 {
-				jsonData, err := json.MarshalIndent(records, "", "  ")
-				if err != nil {
-					outputFile.Close()
-					fmt.Printf("Error: failed to marshal final JSON data: %v\n", err)
-					return
+				// Split lines written whole, with the mappings applied
+				var splits []qif.Split
+				if len(t.Splits) > 0 {
+					record.SplitID = fmt.Sprintf("%s-%d", accountName, exp.transactions)
+					if strings.ToUpper(splitMode) != "ROWS" || format.wholeTransactions {
+						splits = qifSplits(validator, t.Splits, categoryMapping, tagMapping, accountMapping)
+						record.Splits = formatSplits(splits)
+					}
 				}
-				_, err = outputFile.Write(jsonData)
-				if err != nil {
-					outputFile.Close()
-					fmt.Printf("Error: failed to write final JSON data to file: %v\n", err)
-					return
+
+				if format.wholeTransactions {
+					// Transfers stay [Account] categories so they still link
+					// the two registers
+					qifCategory := category
+					if bracketed && !ruleResult.SetCategory {
+						qifCategory = "[" + applyMapping(counterpart, accountMapping) + "]"
+					}
+					record.transaction = qifTransaction(t, record, qifCategory, splits)
 				}
-				records = nil
-			} else if strings.ToUpper(outputFormat) == "XML" && len(records) > 0 {
-				xmlData, err := xml.MarshalIndent(transactionList{Transactions: records}, "", "  ")
-				if err != nil {
-					outputFile.Close()
-					fmt.Printf("Error: failed to marshal final XML data: %v\n", err)
-					return
+
+				// The entry written for one half of a transfer holds both
+				if format.pairTransfers && record.TransferID != "" && !session.firstSide(record.TransferID) {
+					continue
 				}
-				_, err = outputFile.Write(xmlData)
-				if err != nil {
-					outputFile.Close()
-					fmt.Printf("Error: failed to write final XML data to file: %v\n", err)
+
+				if err := exp.write(record); err != nil {
+					fmt.Printf("failed to write transaction: %v\n", err)
 					return
 				}
-				records = nil
 			}
-			outputFile.Close()
 		}
+		if accountsFound == 0 {
+			fmt.Println("No matches found.")
+		}
+
+		// Report every record the parser skipped or couldn't fully read, so
+		// nothing disappears from the export without a trace
+		reportParseProblems(validator, reader.Problems(), selectedAccountList)
+
+		// Finish every open file
+		for _, exp := range exportOrder {
+			if err := exp.close(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+			fmt.Printf("\n%s: %d transactions found, %d records written\n", exp.name, exp.transactions, exp.records)
+		}
+		if format.finish != nil {
+			if err := format.finish(session); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return
+			}
+		}
+
+		for _, group := range duplicates.Groups() {
+			validator.AddAccountDuplicate(strings.Join(group.Accounts, ", "), group.Date.Format("2006-01-02"), group.Payee, group.Amount.String(), group.Count, group.Exact)
+		}
+		recordBalanceMismatches(validator, reconciler.Results())
+
+		recordUnusedMappings(validator, map[string]*mapping.Mapping{
+			"Category": categoryMapping,
+			"Payee":    payeeMapping,
+			"Account":  accountMapping,
+			"Tag":      tagMapping,
+		})
 
 		// Print summary
 		fmt.Println("\nExport Summary:")
@@ -671,9 +748,15 @@ MAPPING FILES:
 		} else {
 			fmt.Println("Processed all accounts")
 		}
+		if droppedByRules > 0 {
+			fmt.Printf("Dropped by rules: %d records\n", droppedByRules)
+		}
+		if validator.RemovedDuplicates > 0 {
+			fmt.Printf("Removed duplicates: %d records\n", validator.RemovedDuplicates)
+		}
 		fmt.Printf("Output directory: %s\n", outputPath)
-		if maxRecordsPerFile > 0 {
-			fmt.Printf("Split files: %d records per file (for Monarch compatibility)\n", maxRecordsPerFile)
+		if session.maxRecords > 0 {
+			fmt.Printf("Split files: %d records per file\n", session.maxRecords)
 		}
 		fmt.Println("\nExport completed successfully!")
 
@@ -692,7 +775,7 @@ func init() {
 
 	// Add command-specific flags
 	transactionsCmd.Flags().StringVarP(&outputFields, "outputFields", "", "", "Comma Separated list of fields to export from the QIF File.")
-	transactionsCmd.Flags().StringVarP(&outputFormat, "outputFormat", "f", "CSV", "Output format (CSV, JSON, XML, MONARCH).")
+	transactionsCmd.Flags().StringVarP(&outputFormat, "outputFormat", "f", "CSV", "Output format (CSV, JSON, XML, MONARCH, YNAB, ACTUAL, FIREFLY, LUNCHMONEY, TILLER, OFX, OFX2, QIF, LEDGER, HLEDGER, BEANCOUNT); list prints them all.")
 	transactionsCmd.Flags().StringVarP(&csvColumns, "csvColumns", "", DefaultMonarchColumns, "Comma-separated list of columns for CSV output (only used with CSV format). Default is Monarch Money format.")
 	transactionsCmd.Flags().StringVarP(&accountMappingFile, "accountMapFile", "a", "", "Supplied mapping file for accounts. Optional.")
 	transactionsCmd.Flags().StringVarP(&categoryMappingFile, "categoryMapFile", "c", "", "Supplied mapping file for categories. Optional.")
@@ -700,6 +783,13 @@ func init() {
 	transactionsCmd.Flags().StringVarP(&tagMappingFile, "tagMapFile", "t", "", "Supplied mapping file for tags. Optional.")
 	transactionsCmd.Flags().IntVarP(&maxRecordsPerFile, "recordsPerFile", "r", 5000, "Optional. Maximum number of records per CSV file. Default is 5000. If set to 0, all records will be written to a single file.")
 	transactionsCmd.Flags().BoolVarP(&addTagForImport, "addTagForImport", "", true, "Add a custom tag to the transaction for import purposes")
+	transactionsCmd.Flags().StringVarP(&splitMode, "splitMode", "", "COLUMN", "How split transactions are exported: COLUMN (one row, splits in the Splits column) or ROWS (one row per split line).")
+	transactionsCmd.Flags().StringVarP(&rulesFile, "rulesFile", "", "", "YAML or JSON rules file that categorizes, renames, tags or drops transactions. Optional.")
+	transactionsCmd.Flags().StringVarP(&transferCategory, "transferCategory", "", "Transfer", "Category written for transfers between accounts (QIF [Account] categories). Empty keeps the bracketed account name.")
+	transactionsCmd.Flags().StringVarP(&transferTag, "transferTag", "", "", "Tag added to transfers between accounts. Optional.")
+	transactionsCmd.Flags().BoolVarP(&matchTransfers, "matchTransfers", "", false, "Pair each transfer with its counterpart in the other account and report transfers that have none")
+	transactionsCmd.Flags().BoolVarP(&dedupe, "dedupe", "", false, "Leave out transactions identical in every field to an earlier transaction in the same account")
+	transactionsCmd.Flags().BoolVarP(&duplicatesAcrossAccounts, "duplicatesAcrossAccounts", "", false, "Report potential duplicates (same date, amount and payee) across all accounts instead of within each account")
 	transactionsCmd.Flags().BoolVarP(&skipZeroAmounts, "skipZeroAmounts", "", false, "Skip transactions with zero amount (0.00 or 0)")
 
 	// Mark the shared required flags as required for this command
@@ -707,63 +797,162 @@ func init() {
 	transactionsCmd.MarkPersistentFlagRequired("outputPath")
 }
 
-func loadMapping(filePath string) (map[string]string, error) {
-	mapping := make(map[string]string)
+// loadMapping loads the named mapping file and lists its rules. A blank
+// path loads an empty mapping.
+func loadMapping(kind, filePath string) (*mapping.Mapping, error) {
+	if filePath == "" {
+		fmt.Printf("No %s mapping file specified.\n", strings.ToLower(kind))
+		return mapping.New(), nil
+	}
 
-	file, err := os.Open(filePath)
+	m, err := mapping.Load(filePath)
 	if err != nil {
-		fmt.Println("Error opening file:", err)
+		fmt.Printf("Error loading %s mapping: %v\n", strings.ToLower(kind), err)
 		return nil, err
 	}
-	defer file.Close()
+	fmt.Printf("%d %s Mappings Loaded:\n", m.Len(), kind)
+	for _, rule := range m.Rules {
+		fmt.Printf("  %s -> %s\n", rule.Source, rule.Target)
+	}
+	return m, nil
+}
 
-	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1 // Allow flexible line lengths
+// applyMapping returns the target of the first matching rule, or input if none matches
+func applyMapping(input string, m *mapping.Mapping) string {
+	if m.Match(input) == nil {
+		return input
+	}
+	output := m.Apply(input)
+	fmt.Printf("Mapping: %s -> %s\n", input, output)
+	return output
+}
 
-	for {
-		record, err := reader.Read()
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			fmt.Println("Error reading line in file:", filePath, ";", err)
-			return nil, err
+// applyTrackedMapping applies a mapping and, when a mapping file was given,
+// records values that none of its rules cover
+func applyTrackedMapping(validator *utils.ValidationTracker, dataType, input string, m *mapping.Mapping) string {
+	if m.Len() > 0 && input != "" && m.Match(input) == nil {
+		validator.AddUnmatchedData(dataType, input)
+	}
+	return applyMapping(input, m)
+}
+
+// recordUnusedMappings reports the rules of each mapping file that never matched
+func recordUnusedMappings(validator *utils.ValidationTracker, mappings map[string]*mapping.Mapping) {
+	for mappingType, m := range mappings {
+		var sources []string
+		for _, rule := range m.Unused() {
+			sources = append(sources, rule.Source)
 		}
+		validator.RecordUnusedMapping(mappingType, sources)
+	}
+}
 
-		switch len(record) {
-		case 1:
-			// Single field - skip (move on)
+// reportParseProblems prints the problems found while reading the QIF file
+// and adds them to the validation log. Problems in registers that weren't
+// selected are left out.
+func reportParseProblems(validator *utils.ValidationTracker, problems []qif.Problem, selectedAccountList []string) {
+	var reported []qif.Problem
+	for _, p := range problems {
+		if p.Account != "" && len(selectedAccountList) > 0 && !containsString(selectedAccountList, p.Account) {
 			continue
-		case 2:
-			// Two fields - set key-value in map (but skip if value is empty)
-			key := record[0]
-			value := record[1]
-			// Only add mapping if the target value is not empty
-			if value != "" {
-				mapping[key] = value
+		}
+		reported = append(reported, p)
+	}
+	if len(reported) == 0 {
+		return
+	}
+
+	fmt.Printf("\n⚠️  %d problems found while reading %s:\n", len(reported), inputFile)
+	for _, p := range reported {
+		fmt.Printf("  Line %d [%s]: %s\n", p.Line, p.Severity, p.Message)
+		for _, line := range strings.Split(p.Text, "\n") {
+			if line != "" {
+				fmt.Printf("      | %s\n", line)
 			}
-		default:
-			fmt.Println("Unexpected number of fields:", record)
 		}
+		validator.AddParseProblem(p.Line, p.Severity.String(), p.Account, p.Message, p.Text)
+	}
+}
+
+// recordBalanceMismatches reports the accounts whose transactions don't add
+// up to the balance stated in the file
+func recordBalanceMismatches(validator *utils.ValidationTracker, results []qif.Reconciliation) {
+	for _, r := range results {
+		if !r.HasStated || r.Balanced() {
+			continue
+		}
+		asOf := ""
+		if !r.StatedDate.IsZero() {
+			asOf = r.StatedDate.Format("2006-01-02")
+		}
+		validator.AddBalanceMismatch(r.Account, r.Stated.String(), r.Computed.String(), r.Difference().String(), asOf)
 	}
+}
 
-	return mapping, nil
+// inDateRange reports whether date falls within --startDate and --endDate
+func inDateRange(date time.Time) bool {
+	if startDate != "" {
+		startDateTime, _ := time.Parse("2006-01-02", startDate)
+		if date.Before(startDateTime) {
+			return false
+		}
+	}
+	if endDate != "" {
+		endDateTime, _ := time.Parse("2006-01-02", endDate)
+		if date.After(endDateTime) {
+			return false
+		}
+	}
+	return true
 }
 
-func applyMapping(input string, mapping map[string]string) string {
-	// Loop through the mapping and look for the input value. If found, replace it with the mapped value.
-	for oldValue, newValue := range mapping {
-		if oldValue == input {
-			fmt.Printf("Mapping: %s -> %s\n", input, newValue)
-			return newValue
+// scanTransfers reads the whole file once and pairs the two halves of
+// every transfer between accounts. Split transactions aren't paired.
+func scanTransfers(path string) (*qif.TransferMatcher, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	matcher := qif.NewTransferMatcher()
+	positions := make(map[string]int) // Transactions read so far, by account
+	reader := qif.NewReader(file, qifOptions())
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return matcher, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		t := entry.Transaction
+		if t == nil {
+			continue
+		}
+		positions[entry.Account.Name]++
+
+		category, _ := utils.SplitCategoryAndTag(t.Category)
+		counterpart, isTransfer := qif.TransferAccount(category)
+		if !isTransfer || counterpart == entry.Account.Name || len(t.Splits) > 0 {
+			continue
 		}
+		amount, err := money.Parse(t.Amount)
+		if err != nil {
+			continue
+		}
+		matcher.Add(qif.TransferSide{
+			Account:     entry.Account.Name,
+			Counterpart: counterpart,
+			Index:       positions[entry.Account.Name],
+			Date:        t.Date,
+			Amount:      amount,
+		})
 	}
-	// If no mapping is found, return the original input.
-	return input
 }
 
-func writeHeader(f *os.File, h string) error {
-	_, err := f.WriteString(h)
+func writeHeader(w io.Writer, h string) error {
+	_, err := io.WriteString(w, h)
 	return err
 }
 
@@ -773,30 +962,73 @@ func buildCSVRow(record TransactionRecord, columns string) string {
 	values := make([]string, len(columnList))
 
 	for i, col := range columnList {
-		col = strings.TrimSpace(col)
-		switch col {
-		case "Date":
-			values[i] = record.Date
-		case "Merchant":
-			values[i] = record.Merchant
-		case "Category":
-			values[i] = record.Category
-		case "Account":
-			values[i] = record.Account
-		case "Original Statement":
-			values[i] = record.OriginalStatement
-		case "Notes":
-			values[i] = record.Notes
-		case "Amount":
-			values[i] = record.Amount
-		case "Tags":
-			values[i] = record.Tags
-		default:
-			values[i] = ""
-		}
+		values[i] = recordField(record, strings.TrimSpace(col))
+	}
+
+	return quoteCSVRow(values)
+}
+
+// recordField returns the value of the named CSV column, or "" for an unknown column
+func recordField(record TransactionRecord, column string) string {
+	switch column {
+	case "Date":
+		return record.Date
+	case "Merchant":
+		return record.Merchant
+	case "Category":
+		return record.Category
+	case "Account":
+		return record.Account
+	case "Account Type":
+		return record.AccountType
+	case "Original Statement":
+		return record.OriginalStatement
+	case "Notes":
+		return record.Notes
+	case "Amount":
+		return record.Amount
+	case "Tags":
+		return record.Tags
+	case "Split ID":
+		return record.SplitID
+	case "Splits":
+		return record.Splits
+	case "Check Number":
+		return record.CheckNumber
+	case "Cleared":
+		return record.Cleared
+	case "Address":
+		return record.Address
+	case "Transfer Account":
+		return record.TransferAccount
+	case "Transfer ID":
+		return record.TransferID
+	}
+	return ""
+}
+
+// splitEscaper escapes the separators of the Splits column with a backslash
+var splitEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, ";", `\;`)
+
+// formatSplits serializes split lines, as returned by qifSplits, for the
+// Splits column as "Category|Amount|Memo|Tags" entries separated by
+// semicolons. Tags are comma-separated, as in the Tags column.
+func formatSplits(splits []qif.Split) string {
+	entries := make([]string, len(splits))
+	for i, split := range splits {
+		category, tags := utils.SplitCategoryAndTag(split.Category)
+		entries[i] = strings.Join([]string{
+			splitEscaper.Replace(category),
+			formatAmount(split.Amount),
+			splitEscaper.Replace(split.Memo),
+			splitEscaper.Replace(strings.ReplaceAll(tags, ":", ",")),
+		}, "|")
 	}
+	return strings.Join(entries, ";")
+}
 
-	// Build quoted CSV line
+// quoteCSVRow joins values into a CSV line with every field quoted
+func quoteCSVRow(values []string) string {
 	var line strings.Builder
 	for i, val := range values {
 		if i > 0 {
@@ -808,7 +1040,83 @@ func buildCSVRow(record TransactionRecord, columns string) string {
 	return line.String()
 }
 
-func writeTransaction(f *os.File, t string) error {
-	_, err := f.WriteString(t)
+func writeTransaction(w io.Writer, t string) error {
+	_, err := io.WriteString(w, t)
 	return err
 }
+
+// accountExport streams one account's records to numbered output files,
+// starting a new file every maxRecords records
+type accountExport struct {
+	exportAccount
+	exporter     Exporter
+	maxRecords   int // Records per file, 0 for no limit
+	transactions int // Transactions read for the account, including filtered ones
+	records      int // Records written across all files
+
+	fileIndex   int
+	fileRecords int
+	file        *os.File
+}
+
+// open creates the next numbered file and begins it
+func (e *accountExport) open() error {
+	e.fileIndex++
+	e.fileRecords = 0
+	outputFileName := fmt.Sprintf("%s_%d%s", e.name, e.fileIndex, e.exporter.Ext())
+	if e.fileIndex == 1 {
+		fmt.Printf("\nProcessing %s (File %d)\n", e.name, e.fileIndex)
+	} else {
+		fmt.Printf("\nCreating split file for %s (File %d) - Records %d to %d\n",
+			e.name,
+			e.fileIndex,
+			(e.fileIndex-1)*e.maxRecords+1,
+			e.fileIndex*e.maxRecords)
+	}
+
+	file, err := os.Create(filepath.Join(outputPath, outputFileName))
+	if err != nil {
+		return fmt.Errorf("creating file %s: %w", outputFileName, err)
+	}
+	e.file = file
+
+	if err := e.exporter.Begin(file, outputFileName); err != nil {
+		return fmt.Errorf("failed to write header to %s: %w", outputFileName, err)
+	}
+	return nil
+}
+
+// write appends a record to the current file, moving on to a new file
+// once the current one holds maxRecords records
+func (e *accountExport) write(record TransactionRecord) error {
+	if e.maxRecords != 0 && e.fileRecords == e.maxRecords {
+		if err := e.close(); err != nil {
+			return err
+		}
+		if err := e.open(); err != nil {
+			return err
+		}
+	}
+
+	if err := e.exporter.WriteRecord(record); err != nil {
+		return err
+	}
+	e.fileRecords++
+	e.records++
+	return nil
+}
+
+// close finishes and closes the current file. It is safe to call more than once.
+func (e *accountExport) close() error {
+	if e.file == nil {
+		return nil
+	}
+	file := e.file
+	e.file = nil
+
+	if err := e.exporter.End(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to finish %s: %w", file.Name(), err)
+	}
+	return file.Close()
+}
@@ -1,11 +1,15 @@
 package cmd
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"qifutil/pkg/qif"
 	"qifutil/test"
 )
 
@@ -180,3 +184,1014 @@ func TestCSVDefaultEqualsMonarch(t *testing.T) {
 		t.Error("MONARCH format should produce identical output to CSV with default columns")
 	}
 }
+
+func TestAccountTypeColumn(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "account_types.qif")
+	helper.CopyTestData("account_types.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Date,Account,Account Type,Amount"
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// Cash and Oth L registers are exported alongside Bank and CCard
+	walletFile := filepath.Join(outputDir, "Wallet_1.csv")
+	helper.AssertFileExists(walletFile)
+	helper.AssertFileContains(walletFile, `"2023-02-01","Wallet","Cash","-20.00"`)
+
+	mortgageFile := filepath.Join(outputDir, "Mortgage_1.csv")
+	helper.AssertFileExists(mortgageFile)
+	helper.AssertFileContains(mortgageFile, `"Mortgage","Oth L","1250.00"`)
+}
+
+func TestSplitModeRows(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "splits.qif")
+	helper.CopyTestData("splits.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Date,Merchant,Category,Notes,Amount,Split ID"
+	splitMode = "ROWS"
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() { splitMode = "COLUMN" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.csv")
+	helper.AssertFileContains(checkingFile, `"2023-03-04","Target","Food:Groceries","Weekly run","-60.00","Checking Account-1"`)
+	helper.AssertFileContains(checkingFile, `"2023-03-04","Target","Household","Paper towels","-40.00","Checking Account-1"`)
+	// Transactions without splits are unchanged
+	helper.AssertFileContains(checkingFile, `"2023-03-05","Coffee Shop","Food:Dining","","-12.00",""`)
+
+	content, _ := os.ReadFile(checkingFile)
+	if strings.Contains(string(content), "-100.00") {
+		t.Error("ROWS mode should not write the parent amount")
+	}
+}
+
+func TestSplitModeColumn(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "splits.qif")
+	helper.CopyTestData("splits.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Date,Merchant,Category,Amount,Split ID,Splits"
+	splitMode = "COLUMN"
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.csv")
+	helper.AssertFileContains(checkingFile, `"2023-03-04","Target","Food:Groceries","-100.00","Checking Account-1","Food:Groceries|-60.00||;Household|-40.00|Paper towels|"`)
+}
+
+func TestSplitsColumnMappings(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "splits.qif")
+	os.WriteFile(sourceFile, []byte("!Account\nNChecking\nTBank\n^\n!Type:Bank\n"+
+		"D3/4/2023\nT-100.00\nPTarget\nLHousehold\n"+
+		"SHousehold/Vacation\nEPaper towels; 2|pack\n$-60.00\n"+
+		"S[Savings]\n$-40.00\n^\n"), 0644)
+	categoryFile := filepath.Join(tempDir, "categories.csv")
+	os.WriteFile(categoryFile, []byte(`"Household","Home:Supplies"`+"\n"), 0644)
+	tagFile := filepath.Join(tempDir, "tags.csv")
+	os.WriteFile(tagFile, []byte(`"Vacation","Trip"`+"\n"), 0644)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Category,Splits"
+	splitMode = "COLUMN"
+	addTagForImport = false
+	categoryMappingFile = categoryFile
+	tagMappingFile = tagFile
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() {
+		csvColumns = DefaultMonarchColumns
+		addTagForImport = true
+		categoryMappingFile = ""
+		tagMappingFile = ""
+	}()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	helper.AssertFileContains(filepath.Join(outputDir, "Checking_1.csv"),
+		`"Home:Supplies","Home:Supplies|-60.00|Paper towels\; 2\|pack|Trip;[Savings]|-40.00||"`)
+}
+
+func TestStreamingFormatsSplitFiles(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "splits.qif")
+	helper.CopyTestData("splits.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	splitMode = "COLUMN"
+	maxRecordsPerFile = 1
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() { maxRecordsPerFile = 5000 }()
+
+	// Each file is closed off on its own, so every part is a complete document
+	outputFormat = "JSON"
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	for _, name := range []string{"Checking Account_1.json", "Checking Account_2.json"} {
+		content, _ := os.ReadFile(filepath.Join(outputDir, name))
+		var records []TransactionRecord
+		if err := json.Unmarshal(content, &records); err != nil || len(records) != 1 {
+			t.Errorf("%s: expected 1 JSON record, got %d (%v)", name, len(records), err)
+		}
+	}
+
+	outputFormat = "XML"
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	content, _ := os.ReadFile(filepath.Join(outputDir, "Checking Account_2.xml"))
+	var list struct {
+		Transactions []TransactionRecord `xml:"transaction"`
+	}
+	if err := xml.Unmarshal(content, &list); err != nil || len(list.Transactions) != 1 {
+		t.Fatalf("Expected 1 XML transaction, got %d (%v)", len(list.Transactions), err)
+	}
+	if list.Transactions[0].Merchant != "Coffee Shop" {
+		t.Errorf("Unexpected XML transaction %+v", list.Transactions[0])
+	}
+}
+
+func TestTransferMatching(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "transfers.qif")
+	helper.CopyTestData("transfers.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Date,Merchant,Category,Amount,Transfer Account,Transfer ID"
+	matchTransfers = true
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() { matchTransfers = false }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.csv")
+	savingsFile := filepath.Join(outputDir, "Savings_1.csv")
+	helper.AssertFileContains(checkingFile, `"2023-01-10","Transfer to savings","Transfer","-500.00","Savings","T1"`)
+	helper.AssertFileContains(savingsFile, `"2023-01-10","Transfer from checking","Transfer","500.00","Checking Account","T1"`)
+	// No Visa register in the file, so the payment has no counterpart
+	helper.AssertFileContains(checkingFile, `"2023-01-20","Card payment","Transfer","-200.00","Visa",""`)
+	// The opening balance names its own account and isn't a transfer
+	helper.AssertFileContains(checkingFile, `"Opening Balance","Opening Balance","1000.00","",""`)
+
+	logFile := filepath.Join(outputDir, "transactions_validation.log")
+	helper.AssertFileContains(logFile, "Unmatched transfers: 1 transfers have no counterpart")
+	helper.AssertFileContains(logFile, "Account: Checking Account | To: Visa | Amount: -200.00")
+}
+
+func TestTransfersNotReportedAsUnmapped(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "transfers.qif")
+	helper.CopyTestData("transfers.qif", sourceFile)
+	categoryFile := filepath.Join(tempDir, "categories.csv")
+	os.WriteFile(categoryFile, []byte(`"Food:Groceries","Groceries"`+"\n"), 0644)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Date,Merchant,Category,Amount"
+	inputFile = sourceFile
+	outputPath = outputDir
+	categoryMappingFile = categoryFile
+	defer func() { categoryMappingFile = "" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	helper.AssertFileContains(filepath.Join(outputDir, "Checking Account_1.csv"), `"2023-01-10","Transfer to savings","Transfer","-500.00"`)
+	// The opening balance, a transfer to the account itself, gets a category of its own
+	helper.AssertFileContains(filepath.Join(outputDir, "Checking Account_1.csv"), `"2023-01-01","Opening Balance","Opening Balance","1000.00"`)
+	content, _ := os.ReadFile(filepath.Join(outputDir, "transactions_validation.log"))
+	if strings.Contains(string(content), "Unmapped values") {
+		t.Errorf("Expected transfers not to be reported as unmapped, got:\n%s", content)
+	}
+}
+
+func TestHeaderlessBankFile(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "bank_download.qif")
+	helper.CopyTestData("bank_download.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Date,Merchant,Account,Amount"
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	// The account is named after the file by default
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	defaultFile := filepath.Join(outputDir, "bank_download_1.csv")
+	helper.AssertFileExists(defaultFile)
+	helper.AssertFileContains(defaultFile, `"2023-03-01","Corner Bakery","bank_download","-25.00"`)
+
+	// --accountName overrides it, and the name flows through --accounts
+	defaultAccountName = "Everyday Checking"
+	selectedAccounts = "Everyday Checking"
+	defer func() { defaultAccountName = ""; selectedAccounts = "" }()
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	namedFile := filepath.Join(outputDir, "Everyday Checking_1.csv")
+	helper.AssertFileExists(namedFile)
+	helper.AssertFileContains(namedFile, `"2023-03-02","Payroll Deposit","Everyday Checking","1500.00"`)
+}
+
+func TestCheckNumberClearedAddressColumns(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "checks.qif")
+	helper.CopyTestData("checks.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Date,Merchant,Check Number,Cleared,Address,Amount"
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.csv")
+	helper.AssertFileContains(checkingFile, `"2023-04-03","City Water Department","2047","R","1200 Main Street, Springfield, IL 62701","-150.00"`)
+	helper.AssertFileContains(checkingFile, `"2023-04-05","Corner Store","","*","","-20.00"`)
+
+	// The same fields are carried in JSON
+	outputFormat = "JSON"
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	jsonFile := filepath.Join(outputDir, "Checking Account_1.json")
+	helper.AssertFileContains(jsonFile, `"check_number": "2047"`)
+	helper.AssertFileContains(jsonFile, `"cleared": "R"`)
+	helper.AssertFileContains(jsonFile, `"address": "1200 Main Street, Springfield, IL 62701"`)
+}
+
+func TestPatternMappings(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "sample.qif")
+	helper.CopyTestData("sample.qif", sourceFile)
+
+	payeeFile := filepath.Join(tempDir, "payees.csv")
+	os.WriteFile(payeeFile, []byte(`"re:(?i)gas station","Fuel Stop"`+"\n"+`"Uber*","Uber"`+"\n"), 0644)
+	categoryFile := filepath.Join(tempDir, "categories.csv")
+	os.WriteFile(categoryFile, []byte(`"Travel:*","Travel"`+"\n"+`"Travel:Hotels","Lodging"`+"\n"), 0644)
+
+	selectedAccounts = "Checking Account"
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Merchant,Category,Amount"
+	inputFile = sourceFile
+	outputPath = outputDir
+	payeeMappingFile = payeeFile
+	categoryMappingFile = categoryFile
+	defer func() { selectedAccounts = ""; payeeMappingFile = ""; categoryMappingFile = "" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.csv")
+	helper.AssertFileContains(checkingFile, `"Fuel Stop","Transportation:Fuel","-35.50"`)
+	helper.AssertFileContains(checkingFile, `"Fuel Stop","Transportation:Fuel","-28.50"`)
+	helper.AssertFileContains(checkingFile, `"Uber","Travel","-65.00"`)
+	// The exact rule beats the earlier wildcard
+	helper.AssertFileContains(checkingFile, `"Mariott Hotel","Lodging","-250.00"`)
+	helper.AssertFileContains(checkingFile, `"Alaska Airlines","Travel","-162.06"`)
+}
+
+func TestRulesFile(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "sample.qif")
+	helper.CopyTestData("sample.qif", sourceFile)
+
+	rulesPath := filepath.Join(tempDir, "rules.yaml")
+	os.WriteFile(rulesPath, []byte(`rules:
+  - name: Fuel
+    when:
+      payee: {contains: gas station}
+      amount: {max: -30}
+    then:
+      category: Auto:Fuel
+      payee: Gas
+      addTags: [Car]
+  - name: Streaming
+    when:
+      category: {equals: Subscriptions:Entertainment}
+    then:
+      drop: true
+`), 0644)
+
+	selectedAccounts = "Checking Account"
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Merchant,Original Statement,Category,Amount,Tags"
+	inputFile = sourceFile
+	outputPath = outputDir
+	rulesFile = rulesPath
+	defer func() { selectedAccounts = ""; rulesFile = "" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.csv")
+	helper.AssertFileContains(checkingFile, `"Gas","COSTCO GAS STATION","Auto:Fuel","-35.50","QIFIMPORT,Car"`)
+	// -28.50 is above the rule's maximum, so it keeps its QIF category
+	helper.AssertFileContains(checkingFile, `"Shell Gas Station","Shell Gas Station","Transportation:Fuel","-28.50","QIFIMPORT"`)
+
+	content, _ := os.ReadFile(checkingFile)
+	if strings.Contains(string(content), "Netflix") {
+		t.Error("Dropped transactions should not be exported")
+	}
+}
+
+func TestMappingUsageInValidationLog(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "sample.qif")
+	helper.CopyTestData("sample.qif", sourceFile)
+
+	categoryFile := filepath.Join(tempDir, "categories.csv")
+	os.WriteFile(categoryFile, []byte(`"Food:Groceries","Groceries"`+"\n"+`"Pets","Pet Care"`+"\n"+`"re:^Travel:","Travel"`+"\n"), 0644)
+
+	selectedAccounts = "Savings Account"
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = DefaultMonarchColumns
+	inputFile = sourceFile
+	outputPath = outputDir
+	categoryMappingFile = categoryFile
+	defer func() { selectedAccounts = ""; categoryMappingFile = "" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	logFile := filepath.Join(outputDir, "transactions_validation.log")
+	helper.AssertFileContains(logFile, "Category mapping: 3 rules never used")
+	helper.AssertFileContains(logFile, `- "Pets"`)
+	helper.AssertFileContains(logFile, `- "re:^Travel:"`)
+	// The Savings register's three "Income" transactions have no mapping
+	helper.AssertFileContains(logFile, `- "category:Income": appears 3 times`)
+}
+
+func TestDuplicateDetection(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "duplicates.qif")
+	helper.CopyTestData("duplicates.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Date,Merchant,Notes,Amount"
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// Without --dedupe every transaction is written and the group is reported
+	checkingFile := filepath.Join(outputDir, "Checking_1.csv")
+	content, _ := os.ReadFile(checkingFile)
+	if got := strings.Count(string(content), `"Whole Foods Market","Weekly groceries"`); got != 2 {
+		t.Errorf("Expected both copies without --dedupe, got %d", got)
+	}
+	logFile := filepath.Join(outputDir, "transactions_validation.log")
+	helper.AssertFileContains(logFile, "Potential duplicates: 1 groups detected")
+	helper.AssertFileContains(logFile, "Account: Checking | Date: 2023-01-08 | Payee: Whole Foods Market | Amount: -45.23 (appears 3 times, exact copies: 1)")
+
+	// --dedupe drops the exact copy but keeps the one with a different memo;
+	// across accounts, the Shell purchase in both registers is reported too
+	dedupe = true
+	duplicatesAcrossAccounts = true
+	defer func() { dedupe = false; duplicatesAcrossAccounts = false }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	content, _ = os.ReadFile(checkingFile)
+	if got := strings.Count(string(content), `"Whole Foods Market","Weekly groceries"`); got != 1 {
+		t.Errorf("Expected one copy with --dedupe, got %d", got)
+	}
+	helper.AssertFileContains(checkingFile, `"WHOLE FOODS MARKET","One Step Update"`)
+	helper.AssertFileContains(logFile, "Potential duplicates: 2 groups detected")
+	helper.AssertFileContains(logFile, "(Removed exact copies: 1)")
+	helper.AssertFileContains(logFile, "Account: Checking, Visa | Date: 2023-01-10 | Payee: Shell | Amount: -35.50 (appears 2 times)")
+}
+
+func TestUnreadableRecordsReported(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "malformed.qif")
+	helper.CopyTestData("malformed.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = DefaultMonarchColumns
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	output := helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// The record with an unreadable date is skipped but reported with its text
+	helper.AssertOutputContains(output, `Line 11 [error]: transaction skipped: unreadable date "13/45'23"`)
+	helper.AssertOutputContains(output, "      | PBad Date")
+
+	logFile := filepath.Join(outputDir, "transactions_validation.log")
+	helper.AssertFileContains(logFile, `- Line 11 [error]: transaction skipped: unreadable date "13/45'23" (Account: Checking)`)
+	helper.AssertFileContains(logFile, "      | D13/45'23\n      | T-10.00\n      | PBad Date\n")
+	helper.AssertFileContains(logFile, "- Line 15 [warning]: splits add up to -90.00 but the transaction amount is -100.00")
+	helper.AssertFileContains(logFile, "- Line 29 [warning]: record is not terminated by a ^ line")
+}
+
+func TestMissingTerminatorReported(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "merged.qif")
+	os.WriteFile(sourceFile, []byte("!Account\nNChecking\nTBank\n^\n!Type:Bank\n"+
+		"D1/5/2023\nT-10.00\nPFirst\n^\n"+
+		"D1/6/2023\nT-20.00\nPSecond\n"+
+		"D1/7/2023\nT-30.00\nPThird\n^\n"), 0644)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = "Date,Merchant,Amount"
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() { csvColumns = DefaultMonarchColumns }()
+
+	output := helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// Both records are written, and the missing ^ is reported with their lines
+	checkingFile := filepath.Join(outputDir, "Checking_1.csv")
+	helper.AssertFileContains(checkingFile, `"2023-01-06","Second","-20.00"`)
+	helper.AssertFileContains(checkingFile, `"2023-01-07","Third","-30.00"`)
+	message := "record has no ^ line before line 13, where another D starts; lines 13-15 are read as the next record"
+	helper.AssertOutputContains(output, "Line 10 [error]: "+message)
+	helper.AssertOutputContains(output, "      | PSecond\n      | D1/7/2023\n")
+
+	logFile := filepath.Join(outputDir, "transactions_validation.log")
+	helper.AssertFileContains(logFile, "- Line 10 [error]: "+message+" (Account: Checking)")
+	helper.AssertFileContains(logFile, "      | D1/6/2023\n      | T-20.00\n      | PSecond\n      | D1/7/2023\n      | T-30.00\n      | PThird\n")
+}
+
+func TestBalanceMismatchInValidationLog(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "reconcile.qif")
+	helper.CopyTestData("reconcile.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "CSV"
+	csvColumns = DefaultMonarchColumns
+	inputFile = sourceFile
+	outputPath = outputDir
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	logFile := filepath.Join(outputDir, "transactions_validation.log")
+	helper.AssertFileContains(logFile, "Balance mismatches: 1 accounts don't add up to their stated balance")
+	helper.AssertFileContains(logFile, "Account: Savings | Stated: 600.00 | Transactions: 575.00 | Difference: 25.00")
+}
+
+func TestOFXFormats(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "sample.qif")
+	helper.CopyTestData("sample.qif", sourceFile)
+
+	selectedAccounts = "Checking Account,CreditCard Account"
+	startDate = "2023-02-01"
+	endDate = "2023-02-28"
+	outputFormat = "OFX"
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() { selectedAccounts = ""; startDate = ""; endDate = ""; outputFormat = "CSV" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.ofx")
+	helper.AssertFileContains(checkingFile, "OFXHEADER:100\nDATA:OFXSGML\nVERSION:102\nSECURITY:NONE\nENCODING:UNICODE\nCHARSET:NONE\n")
+	helper.AssertFileContains(checkingFile, "<BANKID>000000000\n<ACCTID>Checking Account\n<ACCTTYPE>CHECKING\n")
+	// The statement covers the filtered date range
+	helper.AssertFileContains(checkingFile, "<DTSTART>20230201\n<DTEND>20230228\n")
+	helper.AssertFileContains(checkingFile, "<TRNTYPE>DEBIT\n<DTPOSTED>20230202\n<TRNAMT>-45.99\n")
+	helper.AssertFileContains(checkingFile, "<NAME>Best Buy\n<MEMO>Samsung USB drives\n")
+
+	// Credit card registers become credit card statements
+	cardFile := filepath.Join(outputDir, "CreditCard Account_1.ofx")
+	helper.AssertFileContains(cardFile, "<CREDITCARDMSGSRSV1>")
+	helper.AssertFileContains(cardFile, "<CCACCTFROM>\n<ACCTID>CreditCard Account\n</CCACCTFROM>\n")
+
+	// FITIDs are the same every time the file is exported
+	fitids := func(path string) []string {
+		content, _ := os.ReadFile(path)
+		var ids []string
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.HasPrefix(line, "<FITID>") {
+				ids = append(ids, line)
+			}
+		}
+		return ids
+	}
+	first := fitids(checkingFile)
+
+	outputFormat = "OFX2"
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	content, _ := os.ReadFile(checkingFile)
+	if !strings.HasPrefix(string(content), "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n<?OFX OFXHEADER=\"200\" VERSION=\"211\"") {
+		t.Errorf("Expected an OFX 2 header, got:\n%s", content)
+	}
+	decoder := xml.NewDecoder(strings.NewReader(string(content)))
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if err != io.EOF {
+				t.Errorf("OFX2 output is not well-formed XML: %v", err)
+			}
+			break
+		}
+	}
+
+	second := fitids(checkingFile)
+	if len(first) == 0 || len(first) != len(second) {
+		t.Fatalf("Expected the same number of FITIDs, got %d and %d", len(first), len(second))
+	}
+	for i := range first {
+		if first[i]+"</FITID>" != second[i] {
+			t.Errorf("FITID %d changed between exports: %s vs %s", i, first[i], second[i])
+		}
+	}
+}
+
+func TestOFXBalanceAndSplits(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	reconcileFile := filepath.Join(tempDir, "reconcile.qif")
+	helper.CopyTestData("reconcile.qif", reconcileFile)
+
+	selectedAccounts = ""
+	startDate = "2023-02-01"
+	endDate = ""
+	outputFormat = "OFX"
+	inputFile = reconcileFile
+	outputPath = outputDir
+	defer func() { startDate = ""; outputFormat = "CSV"; splitMode = "COLUMN"; payeeMappingFile = "" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// The stated balance, not the sum of the filtered transactions
+	helper.AssertFileContains(filepath.Join(outputDir, "Checking_1.ofx"), "<LEDGERBAL>\n<BALAMT>1454.77\n<DTASOF>20230331\n</LEDGERBAL>\n")
+
+	// A split transaction is one entry whatever the split mode
+	splitsFile := filepath.Join(tempDir, "splits.qif")
+	helper.CopyTestData("splits.qif", splitsFile)
+	startDate = ""
+	splitMode = "ROWS"
+	inputFile = splitsFile
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.ofx")
+	helper.AssertFileContains(checkingFile, "<TRNAMT>-100.00\n")
+	// Without a stated balance, the ledger balance is the running total
+	helper.AssertFileContains(checkingFile, "<LEDGERBAL>\n<BALAMT>-112.00\n<DTASOF>20230305\n</LEDGERBAL>\n")
+	content, _ := os.ReadFile(checkingFile)
+	if strings.Contains(string(content), "<TRNAMT>-60.00") {
+		t.Errorf("Expected split lines not to be separate entries, got:\n%s", content)
+	}
+
+	// Mapping the payees doesn't change the FITIDs
+	fitids := func() string {
+		content, _ := os.ReadFile(checkingFile)
+		var ids []string
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.HasPrefix(line, "<FITID>") {
+				ids = append(ids, line)
+			}
+		}
+		return strings.Join(ids, "\n")
+	}
+	before := fitids()
+	payeeFile := filepath.Join(tempDir, "payees.csv")
+	os.WriteFile(payeeFile, []byte(`"Target","Target Stores"`+"\n"), 0644)
+	payeeMappingFile = payeeFile
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	helper.AssertFileContains(checkingFile, "<NAME>Target Stores\n")
+	if after := fitids(); before == "" || after != before {
+		t.Errorf("Expected the same FITIDs after mapping payees, got:\n%s\nand:\n%s", before, after)
+	}
+}
+
+func TestQIFFormat(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "splits.qif")
+	helper.CopyTestData("splits.qif", sourceFile)
+
+	categoryFile := filepath.Join(tempDir, "categories.csv")
+	os.WriteFile(categoryFile, []byte(`"Household","Home:Supplies"`+"\n"), 0644)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "QIF"
+	splitMode = "ROWS" // Ignored: QIF keeps splits inside their transaction
+	inputFile = sourceFile
+	outputPath = outputDir
+	categoryMappingFile = categoryFile
+	defer func() { outputFormat = "CSV"; splitMode = "COLUMN"; categoryMappingFile = "" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.qif")
+	helper.AssertFileContains(checkingFile, "!Account\nNChecking Account\nTBank\n^\n!Type:Bank\n")
+	helper.AssertFileContains(checkingFile, "SHome:Supplies\nEPaper towels\n$-40.00\n")
+
+	// The output reads back with its splits and mapped categories
+	f, err := qif.ParseFile(checkingFile, qif.Options{})
+	if err != nil {
+		t.Fatalf("Failed to parse QIF output: %v", err)
+	}
+	if len(f.Accounts) != 1 || len(f.Accounts[0].Transactions) != 2 {
+		t.Fatalf("Expected 1 account with 2 transactions, got %+v", f.Accounts)
+	}
+	target := f.Accounts[0].Transactions[0]
+	if target.Payee != "Target" || target.Amount != "-100.00" || target.Cleared != "X" || len(target.Splits) != 2 {
+		t.Errorf("Unexpected transaction %+v", target)
+	}
+	if target.Splits[0].Category != "Food:Groceries" || target.Splits[1].Category != "Home:Supplies" {
+		t.Errorf("Unexpected splits %+v", target.Splits)
+	}
+}
+
+func TestQIFFormatLists(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "lists.qif")
+	os.WriteFile(sourceFile, []byte("!Type:Cat\nNHousehold\nDCleaning and supplies\nE\n^\nNSupplies\nE\n^\nNSalary\nT\nI\n^\n"+
+		"!Type:Tag\nNVacation\nDTrips away\n^\n"+
+		"!Account\nNChecking\nTBank\n^\n!Type:Bank\n"+
+		"D3/4/2023\nT-40.00\nPTarget\nLHousehold/Vacation\n^\n"+
+		"D3/5/2023\nT1500.00\nPEmployer\nLSalary\n^\n"), 0644)
+	categoryFile := filepath.Join(tempDir, "categories.csv")
+	os.WriteFile(categoryFile, []byte(`"Household","Home:Supplies"`+"\n"+`"Supplies","Home:Supplies"`+"\n"), 0644)
+	tagFile := filepath.Join(tempDir, "tags.csv")
+	os.WriteFile(tagFile, []byte(`"Vacation","Trip"`+"\n"), 0644)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "QIF"
+	addTagForImport = true
+	inputFile = sourceFile
+	outputPath = outputDir
+	categoryMappingFile = categoryFile
+	tagMappingFile = tagFile
+	defer func() { outputFormat = "CSV"; categoryMappingFile = ""; tagMappingFile = "" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// The lists come first, mapped, with each name listed once
+	checkingFile := filepath.Join(outputDir, "Checking_1.qif")
+	content, _ := os.ReadFile(checkingFile)
+	want := "!Type:Cat\nNHome:Supplies\nDCleaning and supplies\nE\n^\nNSalary\nT\nI\n^\n" +
+		"!Type:Tag\nNTrip\nDTrips away\n^\n!Account\nNChecking\n"
+	if !strings.HasPrefix(string(content), want) {
+		t.Errorf("Expected the category and tag lists first, got:\n%s", content)
+	}
+
+	// Files handed back to Quicken don't get the import tag
+	helper.AssertFileContains(checkingFile, "LHome:Supplies/Trip\n")
+	helper.AssertFileContains(checkingFile, "LSalary\n")
+	if strings.Contains(string(content), "QIFIMPORT") {
+		t.Errorf("Expected no QIFIMPORT tag, got:\n%s", content)
+	}
+}
+
+func TestQIFFormatBalance(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "reconcile.qif")
+	helper.CopyTestData("reconcile.qif", sourceFile)
+
+	selectedAccounts = "Checking"
+	startDate = ""
+	endDate = ""
+	outputFormat = "QIF"
+	inputFile = sourceFile
+	outputPath = outputDir
+	defer func() { selectedAccounts = ""; startDate = ""; outputFormat = "CSV" }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// The stated balance is kept for the whole register...
+	checkingFile := filepath.Join(outputDir, "Checking_1.qif")
+	helper.AssertFileContains(checkingFile, "!Account\nNChecking\nTBank\n$1454.77\n/3/31/2023\n^\n")
+
+	// ...and left out when a date filter drops transactions
+	startDate = "2023-02-01"
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	helper.AssertFileContains(checkingFile, "!Account\nNChecking\nTBank\n^\n")
+}
+
+func TestQIFFormatTransfers(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "transfers.qif")
+	helper.CopyTestData("transfers.qif", sourceFile)
+
+	accountFile := filepath.Join(tempDir, "accounts.csv")
+	os.WriteFile(accountFile, []byte(`"Savings","Joint Savings"`+"\n"), 0644)
+
+	selectedAccounts = "Checking Account"
+	startDate = ""
+	endDate = ""
+	outputFormat = "QIF"
+	inputFile = sourceFile
+	outputPath = outputDir
+	accountMappingFile = accountFile
+	tagForImport := addTagForImport
+	addTagForImport = false
+	defer func() {
+		selectedAccounts = ""
+		outputFormat = "CSV"
+		accountMappingFile = ""
+		addTagForImport = tagForImport
+	}()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// Transfers keep their bracketed account, renamed by the account mapping,
+	// instead of --transferCategory
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.qif")
+	helper.AssertFileContains(checkingFile, "PTransfer to savings\nL[Joint Savings]\n")
+	helper.AssertFileContains(checkingFile, "POpening Balance\nL[Checking Account]\n")
+}
+
+func TestBeancountFormat(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "transfers.qif")
+	helper.CopyTestData("transfers.qif", sourceFile)
+
+	selectedAccounts = ""
+	startDate = ""
+	endDate = ""
+	outputFormat = "BEANCOUNT"
+	inputFile = sourceFile
+	outputPath = outputDir
+	tagForImport := addTagForImport
+	addTagForImport = false
+	defer func() { outputFormat = "CSV"; addTagForImport = tagForImport }()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	// A transfer is one entry with a posting to each account
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.beancount")
+	helper.AssertFileContains(checkingFile, "2023-01-10 ! \"Transfer to savings\" \"\"\n"+
+		"  Assets:Checking-Account                           -500.00 USD\n"+
+		"  Assets:Savings                                    500.00 USD\n")
+	helper.AssertFileContains(checkingFile, "  Equity:Opening-Balances                           -1000.00 USD\n")
+	helper.AssertFileContains(checkingFile, "  Expenses:Food:Groceries                           45.23 USD\n")
+
+	// ...and isn't written again from the other account
+	content, _ := os.ReadFile(filepath.Join(outputDir, "Savings_1.beancount"))
+	if strings.Contains(string(content), "Transfer from checking") {
+		t.Errorf("The second half of a transfer should be left out, got:\n%s", content)
+	}
+
+	mainFile := filepath.Join(outputDir, "main.beancount")
+	helper.AssertFileContains(mainFile, "2023-01-01 open Assets:Checking-Account USD\n")
+	helper.AssertFileContains(mainFile, "2023-01-10 open Assets:Savings USD\n")
+	helper.AssertFileContains(mainFile, "2023-01-12 open Expenses:Food:Groceries USD\n")
+	helper.AssertFileContains(mainFile, "include \"Checking Account_1.beancount\"\ninclude \"Savings_1.beancount\"\n")
+}
+
+func TestLedgerFormats(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	outputDir := filepath.Join(tempDir, "output")
+	os.MkdirAll(outputDir, 0755)
+
+	sourceFile := filepath.Join(tempDir, "sample.qif")
+	helper.CopyTestData("sample.qif", sourceFile)
+
+	selectedAccounts = "Checking Account,CreditCard Account"
+	startDate = "2023-02-01"
+	endDate = "2023-03-31"
+	outputFormat = "LEDGER"
+	inputFile = sourceFile
+	outputPath = outputDir
+	tagForImport := addTagForImport
+	addTagForImport = true
+	defer func() {
+		selectedAccounts = ""
+		startDate = ""
+		endDate = ""
+		outputFormat = "CSV"
+		addTagForImport = tagForImport
+	}()
+
+	helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+
+	checkingFile := filepath.Join(outputDir, "Checking Account_1.ledger")
+	helper.AssertFileContains(checkingFile, "2023-02-02 * Best Buy\n    ; Samsung USB drives\n    Assets:")
+	helper.AssertFileContains(checkingFile, "2023-03-06 * Safeway\n    ; Weekly groceries\n    ; :Weekly:\n")
+	// The journal formats don't get the --addTagForImport tag
+	content, _ := os.ReadFile(checkingFile)
+	if strings.Contains(string(content), "QIFIMPORT") {
+		t.Errorf("Expected no QIFIMPORT tag, got:\n%s", content)
+	}
+	helper.AssertFileContains(checkingFile, "    Assets:Checking Account                           $-45.99\n")
+	// Income categories aren't nested under a second Income
+	helper.AssertFileContains(checkingFile, "    Income:Salary                                     $-5000.00\n")
+	helper.AssertFileContains(filepath.Join(outputDir, "main.ledger"), "account Liabilities:CreditCard Account\n")
+
+	// A rule matching only the category list isn't counted as used
+	categoryFile := filepath.Join(tempDir, "categories.csv")
+	os.WriteFile(categoryFile, []byte(`"Fees & Charges:Interest","Bank Fees"`+"\n"), 0644)
+	categoryMappingFile = categoryFile
+	defer func() { categoryMappingFile = "" }()
+
+	outputFormat = "HLEDGER"
+	output := helper.CaptureOutput(func() {
+		transactionsCmd.Run(transactionsCmd, []string{})
+	})
+	if strings.Contains(output, "Mapping: Fees & Charges:Interest") {
+		t.Errorf("Expected the category list not to print mappings, got:\n%s", output)
+	}
+	helper.AssertFileContains(filepath.Join(outputDir, "transactions_validation.log"), "Category mapping: 1 rules never used\n    - \"Fees & Charges:Interest\"\n")
+
+	helper.AssertFileContains(filepath.Join(outputDir, "main.journal"), "account Assets:Checking Account\n")
+	helper.AssertFileContains(filepath.Join(outputDir, "Checking Account_1.journal"), "    ; Weekly:\n")
+}
@@ -0,0 +1,317 @@
+/*
+Copyright © 2025 Chris Gelhaus <chrisgelhaus@live.com>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"qifutil/pkg/money"
+	"qifutil/pkg/qif"
+	"qifutil/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var validateFormat string
+var failOn string
+
+// Exit codes of the validate command
+const (
+	validateExitFailed   = 1 // A problem reached the --failOn severity
+	validateExitNotValid = 2 // The file couldn't be read or the flags are wrong
+)
+
+// validationReport is the result of checking a QIF file
+type validationReport struct {
+	File         string             `json:"file"`
+	Accounts     int                `json:"accounts"`
+	Transactions int                `json:"transactions"`
+	Problems     []qif.Problem      `json:"problems"` // Structural problems, by line
+	Issues       []dataQualityIssue `json:"issues"`   // Data-quality issues from the ValidationTracker
+	Errors       int                `json:"errors"`
+	Warnings     int                `json:"warnings"`
+	Info         int                `json:"info"`
+	FailOn       string             `json:"failOn"`
+	Passed       bool               `json:"passed"`
+}
+
+// dataQualityIssue is a data-quality finding that isn't tied to one line
+type dataQualityIssue struct {
+	Severity qif.Severity `json:"severity"`
+	Code     string       `json:"code"`
+	Count    int          `json:"count"`
+	Message  string       `json:"message"`
+}
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a QIF file for problems without exporting it",
+	Long: `Check a QIF file for problems without writing any exports.
+
+DESCRIPTION:
+  Reads the whole file and reports:
+  - Structural problems, with line numbers: records not terminated by ^
+    (an error when the record runs into the next one, found by a second
+    D line in a register or N line in a list), unknown field codes, unreadable dates and amounts, records the parser
+    skipped, and splits that don't add up to the transaction amount
+  - Data-quality issues: missing payees and categories, zero amounts,
+    potential duplicates, transfers with no counterpart and accounts whose
+    transactions don't add up to the balance stated in the file
+
+  Every finding has a severity: info, warning or error. The command exits
+  with a non-zero code when a finding reaches the --failOn severity, so it
+  can gate conversion scripts.
+
+EXAMPLES:
+  qifutil validate --inputFile data.qif
+  qifutil validate --inputFile data.qif --outputFormat JSON --failOn warning
+
+OPTIONS:
+  --inputFile     Required. Path to the QIF file to check
+  --outputFormat  Optional. TEXT (default) or JSON, written to standard output
+  --failOn        Optional. Lowest severity that fails the check: info,
+                  warning, error (default) or none
+
+EXIT CODES:
+  0  No finding reached the --failOn severity
+  1  At least one finding reached the --failOn severity
+  2  The file could not be read, or a flag is invalid`,
+
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if inputFile == "" {
+			fmt.Println("Error: Missing required flag --inputFile")
+			os.Exit(validateExitNotValid)
+		}
+		if f := strings.ToUpper(validateFormat); f != "TEXT" && f != "JSON" {
+			fmt.Println("Error: Invalid --outputFormat. Use TEXT or JSON")
+			os.Exit(validateExitNotValid)
+		}
+		if !strings.EqualFold(failOn, "none") {
+			if _, err := qif.ParseSeverity(failOn); err != nil {
+				fmt.Println("Error: Invalid --failOn. Use info, warning, error or none")
+				os.Exit(validateExitNotValid)
+			}
+		}
+	},
+
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := validateFile(inputFile)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			os.Exit(validateExitNotValid)
+		}
+		report.judge(failOn)
+
+		if strings.ToUpper(validateFormat) == "JSON" {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				fmt.Println("Error writing report:", err)
+				os.Exit(validateExitNotValid)
+			}
+			fmt.Println(string(data))
+		} else {
+			printValidationReport(report)
+		}
+
+		if !report.Passed {
+			os.Exit(validateExitFailed)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().StringVarP(&validateFormat, "outputFormat", "f", "TEXT", "Report format: TEXT or JSON.")
+	validateCmd.Flags().StringVarP(&failOn, "failOn", "", "error", "Lowest severity that makes the command exit non-zero: info, warning, error or none.")
+}
+
+// validateFile reads a QIF file and collects its structural problems and
+// data-quality issues
+func validateFile(path string) (*validationReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	report := &validationReport{File: path}
+	validator := utils.NewValidationTracker()
+	duplicates := qif.NewDuplicateDetector(false)
+	transfers := qif.NewTransferMatcher()
+	reconciler := qif.NewReconciler()
+	positions := make(map[string]int) // Transactions read so far, by account
+
+	reader := qif.NewReader(file, qifOptions())
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Account != nil {
+			if _, seen := positions[entry.Account.Name]; !seen {
+				positions[entry.Account.Name] = 0
+			}
+		}
+		if entry.Investment != nil {
+			report.Transactions++
+			continue
+		}
+		t := entry.Transaction
+		if t == nil {
+			continue
+		}
+		report.Transactions++
+		accountName := entry.Account.Name
+		positions[accountName]++
+
+		reconciler.Add(entry.Account, t)
+		validator.RecordTransaction()
+		if strings.TrimSpace(t.Payee) == "" {
+			validator.AddMissingPayee()
+		}
+		if strings.TrimSpace(t.Category) == "" && len(t.Splits) == 0 {
+			validator.AddMissingCategory()
+		}
+		amount, amountErr := money.Parse(t.Amount)
+		if amountErr == nil && amount == 0 {
+			validator.AddZeroAmount()
+		}
+		duplicates.Add(accountName, t)
+
+		category, _ := utils.SplitCategoryAndTag(t.Category)
+		if counterpart, isTransfer := qif.TransferAccount(category); isTransfer && amountErr == nil &&
+			counterpart != accountName && len(t.Splits) == 0 {
+			transfers.Add(qif.TransferSide{
+				Account:     accountName,
+				Counterpart: counterpart,
+				Index:       positions[accountName],
+				Date:        t.Date,
+				Amount:      amount,
+			})
+		}
+	}
+	report.Accounts = len(positions)
+
+	for _, group := range duplicates.Groups() {
+		validator.AddAccountDuplicate(strings.Join(group.Accounts, ", "), group.Date.Format("2006-01-02"), group.Payee, group.Amount.String(), group.Count, group.Exact)
+	}
+	for _, side := range transfers.Unmatched() {
+		validator.AddUnmatchedTransfer(side.Date.Format("2006-01-02"), side.Account, side.Counterpart, side.Amount.String())
+	}
+	recordBalanceMismatches(validator, reconciler.Results())
+
+	report.Problems = reader.Problems()
+	if report.Problems == nil {
+		report.Problems = []qif.Problem{}
+	}
+	report.Issues = dataQualityIssues(validator)
+	return report, nil
+}
+
+// dataQualityIssues turns the counts of a ValidationTracker into report issues
+func dataQualityIssues(validator *utils.ValidationTracker) []dataQualityIssue {
+	issues := []dataQualityIssue{}
+	if validator.MissingPayees > 0 {
+		issues = append(issues, dataQualityIssue{qif.SeverityInfo, "missing-payee", validator.MissingPayees,
+			fmt.Sprintf("%d transactions have no payee", validator.MissingPayees)})
+	}
+	if validator.MissingCategory > 0 {
+		issues = append(issues, dataQualityIssue{qif.SeverityInfo, "missing-category", validator.MissingCategory,
+			fmt.Sprintf("%d transactions have no category", validator.MissingCategory)})
+	}
+	if validator.ZeroAmounts > 0 {
+		issues = append(issues, dataQualityIssue{qif.SeverityWarning, "zero-amount", validator.ZeroAmounts,
+			fmt.Sprintf("%d transactions have a zero amount", validator.ZeroAmounts)})
+	}
+	for _, dup := range validator.DuplicateTransactions {
+		issues = append(issues, dataQualityIssue{qif.SeverityWarning, "duplicate", dup.Count,
+			fmt.Sprintf("%s | %s | %s | %s appears %d times", dup.Account, dup.Date, dup.Payee, dup.Amount, dup.Count)})
+	}
+	for _, tr := range validator.UnmatchedTransfers {
+		issues = append(issues, dataQualityIssue{qif.SeverityWarning, "unmatched-transfer", 1,
+			fmt.Sprintf("%s | %s -> %s | %s has no counterpart", tr.Date, tr.Account, tr.Counterpart, tr.Amount)})
+	}
+	for _, b := range validator.BalanceMismatches {
+		issues = append(issues, dataQualityIssue{qif.SeverityWarning, "balance-mismatch", 1,
+			fmt.Sprintf("%s | transactions add up to %s but the stated balance is %s (off by %s)", b.Account, b.Computed, b.Stated, b.Difference)})
+	}
+	return issues
+}
+
+// judge counts the findings by severity and decides whether the file
+// passes with the given --failOn severity
+func (r *validationReport) judge(failOn string) {
+	r.FailOn = strings.ToLower(failOn)
+	r.Errors, r.Warnings, r.Info = 0, 0, 0
+
+	var severities []qif.Severity
+	for _, p := range r.Problems {
+		severities = append(severities, p.Severity)
+	}
+	for _, issue := range r.Issues {
+		severities = append(severities, issue.Severity)
+	}
+
+	threshold, err := qif.ParseSeverity(failOn)
+	r.Passed = true
+	for _, severity := range severities {
+		switch severity {
+		case qif.SeverityError:
+			r.Errors++
+		case qif.SeverityWarning:
+			r.Warnings++
+		default:
+			r.Info++
+		}
+		// --failOn none (or anything unparseable) never fails
+		if err == nil && severity >= threshold {
+			r.Passed = false
+		}
+	}
+}
+
+// printValidationReport writes the report as text
+func printValidationReport(r *validationReport) {
+	fmt.Printf("Validating %s\n", r.File)
+	fmt.Printf("Accounts: %d, Transactions: %d\n", r.Accounts, r.Transactions)
+
+	if len(r.Problems) > 0 {
+		fmt.Printf("\nStructural problems:\n")
+		for _, p := range r.Problems {
+			account := ""
+			if p.Account != "" {
+				account = " (" + p.Account + ")"
+			}
+			fmt.Printf("  line %d: [%s] %s%s\n", p.Line, p.Severity, p.Message, account)
+			for _, line := range strings.Split(p.Text, "\n") {
+				if line != "" {
+					fmt.Printf("      | %s\n", line)
+				}
+			}
+		}
+	}
+
+	if len(r.Issues) > 0 {
+		fmt.Printf("\nData quality:\n")
+		for _, issue := range r.Issues {
+			fmt.Printf("  [%s] %s\n", issue.Severity, issue.Message)
+		}
+	}
+
+	fmt.Printf("\nSummary: %d errors, %d warnings, %d info\n", r.Errors, r.Warnings, r.Info)
+	if r.Passed {
+		fmt.Printf("Result: PASSED (--failOn %s)\n", r.FailOn)
+	} else {
+		fmt.Printf("Result: FAILED (--failOn %s)\n", r.FailOn)
+	}
+}
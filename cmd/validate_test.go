@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"qifutil/pkg/qif"
+	"qifutil/test"
+)
+
+func TestValidateFile(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	sourceFile := filepath.Join(tempDir, "malformed.qif")
+	helper.CopyTestData("malformed.qif", sourceFile)
+
+	report, err := validateFile(sourceFile)
+	if err != nil {
+		t.Fatalf("validateFile failed: %v", err)
+	}
+	if report.Accounts != 1 || report.Transactions != 4 {
+		t.Errorf("Expected 1 account and 4 transactions, got %d and %d", report.Accounts, report.Transactions)
+	}
+
+	codes := make(map[string]int)
+	for _, p := range report.Problems {
+		codes[p.Code] = p.Line
+	}
+	for code, line := range map[string]int{
+		qif.ProblemInvalidDate:   11,
+		qif.ProblemSplitMismatch: 15,
+		qif.ProblemUnknownField:  27,
+		qif.ProblemUnterminated:  29,
+	} {
+		if codes[code] != line {
+			t.Errorf("Expected %s on line %d, got %v", code, line, report.Problems)
+		}
+	}
+
+	issues := make(map[string]bool)
+	for _, issue := range report.Issues {
+		issues[issue.Code] = true
+	}
+	if !issues["zero-amount"] {
+		t.Errorf("Expected a zero-amount issue, got %v", report.Issues)
+	}
+
+	report.judge("error")
+	if report.Passed || report.Errors != 1 {
+		t.Errorf("Expected the unreadable date to fail --failOn error, got %+v", report)
+	}
+	report.judge("none")
+	if !report.Passed {
+		t.Error("--failOn none should always pass")
+	}
+
+	data, _ := json.Marshal(report)
+	if !strings.Contains(string(data), `"severity":"error","code":"invalid-date"`) {
+		t.Errorf("Expected severities by name in JSON, got %s", data)
+	}
+}
+
+func TestValidateCleanFile(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	sourceFile := filepath.Join(tempDir, "sample.qif")
+	helper.CopyTestData("sample.qif", sourceFile)
+
+	report, err := validateFile(sourceFile)
+	if err != nil {
+		t.Fatalf("validateFile failed: %v", err)
+	}
+	report.judge("warning")
+	if !report.Passed || len(report.Problems) != 0 {
+		t.Errorf("Expected sample.qif to pass --failOn warning, got %+v", report)
+	}
+
+	output := helper.CaptureOutput(func() {
+		printValidationReport(report)
+	})
+	helper.AssertOutputContains(output, "Accounts: 3, Transactions: 58")
+	helper.AssertOutputContains(output, "Result: PASSED (--failOn warning)")
+}
+
+func TestValidateMissingTerminator(t *testing.T) {
+	helper := test.NewHelper(t)
+	tempDir := helper.CreateTempDir()
+	sourceFile := filepath.Join(tempDir, "merged.qif")
+	os.WriteFile(sourceFile, []byte("!Account\nNChecking\nTBank\n^\n!Type:Bank\n"+
+		"D1/5/2023\nT-10.00\nPFirst\n^\n"+
+		"D1/6/2023\nT-20.00\nPSecond\n"+
+		"D1/7/2023\nT-30.00\nPThird\n^\n"), 0644)
+
+	report, err := validateFile(sourceFile)
+	if err != nil {
+		t.Fatalf("validateFile failed: %v", err)
+	}
+	if report.Transactions != 3 {
+		t.Errorf("Expected 3 transactions, got %d", report.Transactions)
+	}
+	report.judge("error")
+	if report.Passed || report.Errors != 1 || report.Problems[0].Code != qif.ProblemMissingTerminator || report.Problems[0].Line != 10 {
+		t.Errorf("Expected the missing ^ to fail --failOn error, got %+v", report)
+	}
+}
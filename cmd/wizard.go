@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"qifutil/pkg/config"
+	"qifutil/pkg/money"
 
 	"github.com/spf13/cobra"
 )
@@ -388,7 +389,7 @@ It will ask you questions and help you create the right command for your needs.`
 				balanceInput = strings.TrimSpace(balanceInput)
 
 				// Validate balance is a number
-				if _, err := strconv.ParseFloat(balanceInput, 64); err != nil {
+				if _, err := money.Parse(balanceInput); err != nil {
 					fmt.Printf("Invalid balance: %v. Balance history will not be generated.\n", err)
 					generateBalanceHistoryLocal = false
 				} else {
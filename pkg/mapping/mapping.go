@@ -0,0 +1,197 @@
+// Package mapping loads the "source","target" mapping files used to rename
+// payees, categories, accounts and tags during export.
+package mapping
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks a source that is a regular expression, e.g. re:^AMAZON.*
+const regexPrefix = "re:"
+
+// Rule maps one source value or pattern to a target
+type Rule struct {
+	Source string // Source as written in the mapping file
+	Target string
+	Line   int // Line of the mapping file the rule came from
+	Hits   int // Values Apply has mapped with this rule
+
+	pattern *regexp.Regexp // nil for an exact match
+}
+
+// IsPattern reports whether the rule matches by wildcard or regular expression
+func (r *Rule) IsPattern() bool {
+	return r.pattern != nil
+}
+
+// Mapping is an ordered set of rules. Exact sources are matched first;
+// otherwise the first pattern in file order that matches wins.
+type Mapping struct {
+	Rules    []*Rule          // Every rule in file order
+	exact    map[string]*Rule // Exact rules by source
+	patterns []*Rule          // Wildcard and regular expression rules in file order
+}
+
+// New returns an empty Mapping
+func New() *Mapping {
+	return &Mapping{exact: make(map[string]*Rule)}
+}
+
+// Load reads a mapping file. Each line is a "source","target" pair, optionally
+// followed by further columns that are ignored; a
+// source containing * or ? is a wildcard pattern and a source starting
+// with re: is a regular expression. Lines with an empty target are ignored.
+func Load(path string) (*Mapping, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Read(file)
+}
+
+// Read parses mapping rules from r; see Load for the format
+func Read(r io.Reader) (*Mapping, error) {
+	m := New()
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Allow flexible line lengths
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		// Single fields are skipped, and columns after the target (such as
+		// the occurrence counts in generated files) are ignored
+		if len(record) < 2 {
+			continue
+		}
+		// Only add mapping if the target value is not empty
+		if record[1] == "" {
+			continue
+		}
+		if err := m.Add(record[0], record[1], line); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Add appends a rule. A later exact rule for the same source replaces the
+// earlier one, as loading into a map used to.
+func (m *Mapping) Add(source, target string, line int) error {
+	rule := &Rule{Source: source, Target: target, Line: line}
+
+	switch {
+	case strings.HasPrefix(source, regexPrefix):
+		pattern, err := regexp.Compile(strings.TrimPrefix(source, regexPrefix))
+		if err != nil {
+			return fmt.Errorf("line %d: invalid regular expression %q: %w", line, source, err)
+		}
+		rule.pattern = pattern
+	case strings.ContainsAny(source, "*?"):
+		rule.pattern = globToRegexp(source)
+	}
+
+	if rule.pattern != nil {
+		m.patterns = append(m.patterns, rule)
+	} else {
+		if previous, ok := m.exact[source]; ok {
+			m.remove(previous)
+		}
+		m.exact[source] = rule
+	}
+	m.Rules = append(m.Rules, rule)
+	return nil
+}
+
+// remove drops a rule from Rules
+func (m *Mapping) remove(rule *Rule) {
+	for i, r := range m.Rules {
+		if r == rule {
+			m.Rules = append(m.Rules[:i], m.Rules[i+1:]...)
+			return
+		}
+	}
+}
+
+// Len returns the number of rules
+func (m *Mapping) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.Rules)
+}
+
+// Match returns the rule that applies to value, or nil if none does
+func (m *Mapping) Match(value string) *Rule {
+	if m == nil {
+		return nil
+	}
+	if rule, ok := m.exact[value]; ok {
+		return rule
+	}
+	for _, rule := range m.patterns {
+		if rule.pattern.MatchString(value) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Apply returns the mapped value, or value itself if no rule applies, and
+// counts the hit against the rule that matched
+func (m *Mapping) Apply(value string) string {
+	if rule := m.Match(value); rule != nil {
+		rule.Hits++
+		return rule.Target
+	}
+	return value
+}
+
+// Unused returns the rules Apply has never matched, in file order
+func (m *Mapping) Unused() []*Rule {
+	if m == nil {
+		return nil
+	}
+	var unused []*Rule
+	for _, rule := range m.Rules {
+		if rule.Hits == 0 {
+			unused = append(unused, rule)
+		}
+	}
+	return unused
+}
+
+// globToRegexp converts a wildcard pattern, where * matches any run of
+// characters and ? a single character, into an anchored regular expression
+func globToRegexp(glob string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
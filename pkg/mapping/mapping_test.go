@@ -0,0 +1,103 @@
+package mapping
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleMapping = `"AMAZON MKTPLACE PMTS*2K4","Amazon Exact"
+"re:^AMAZON.*","Amazon"
+"AMAZON*","Amazon Glob"
+"SHELL OIL ?????","Shell"
+"Groceries","Food:Groceries"
+"Unmapped",""
+"Single"
+"Groceries","Food:Supermarket"
+"Costco","Warehouse Club","12"
+`
+
+func TestApplyPrecedence(t *testing.T) {
+	m, err := Read(strings.NewReader(sampleMapping))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		// Exact matches win over patterns that come before them
+		{"AMAZON MKTPLACE PMTS*2K4", "Amazon Exact"},
+		// Otherwise the first pattern in file order wins
+		{"AMAZON MKTPLACE PMTS*9X1", "Amazon"},
+		{"SHELL OIL 12345", "Shell"},
+		{"SHELL OIL 123456", "SHELL OIL 123456"},
+		// A later exact line replaces an earlier one
+		{"Groceries", "Food:Supermarket"},
+		{"Unmapped", "Unmapped"},
+		{"Walmart", "Walmart"},
+		// Columns after the target are ignored
+		{"Costco", "Warehouse Club"},
+	}
+	for _, tt := range tests {
+		if got := m.Apply(tt.input); got != tt.want {
+			t.Errorf("Apply(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	if m.Len() != 6 {
+		t.Errorf("Len() = %d, want 6", m.Len())
+	}
+}
+
+func TestGlobIsAnchoredAndLiteral(t *testing.T) {
+	m := New()
+	if err := m.Add("Food.*", "Food", 1); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	// The dot is literal in a wildcard pattern
+	if got := m.Apply("Food:Dining"); got != "Food:Dining" {
+		t.Errorf("Apply(Food:Dining) = %q, want it unmapped", got)
+	}
+	if got := m.Apply("Food.Dining"); got != "Food" {
+		t.Errorf("Apply(Food.Dining) = %q, want Food", got)
+	}
+}
+
+func TestInvalidRegexp(t *testing.T) {
+	_, err := Read(strings.NewReader(`"Ok","Fine"` + "\n" + `"re:([","Broken"` + "\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected an error naming line 2, got %v", err)
+	}
+}
+
+func TestNilMapping(t *testing.T) {
+	var m *Mapping
+	if got := m.Apply("Anything"); got != "Anything" || m.Len() != 0 {
+		t.Errorf("nil Mapping should map nothing, got %q", got)
+	}
+}
+
+func TestUnused(t *testing.T) {
+	m, err := Read(strings.NewReader(sampleMapping))
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	m.Apply("AMAZON MKTPLACE PMTS*9X1")
+	m.Apply("AMAZON BOOKS")
+	m.Apply("Groceries")
+	m.Apply("Walmart")
+
+	if hits := m.Match("AMAZON BOOKS").Hits; hits != 2 {
+		t.Errorf("re:^AMAZON.* hits = %d, want 2", hits)
+	}
+
+	var sources []string
+	for _, rule := range m.Unused() {
+		sources = append(sources, rule.Source)
+	}
+	if got := strings.Join(sources, ","); got != "AMAZON MKTPLACE PMTS*2K4,AMAZON*,SHELL OIL ?????,Costco" {
+		t.Errorf("Unused() = %s", got)
+	}
+}
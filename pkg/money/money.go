@@ -0,0 +1,84 @@
+// Package money provides an exact fixed-point amount type so that parsing and
+// summing QIF amounts never picks up float64 rounding error.
+package money
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a monetary value held as a whole number of cents
+type Amount int64
+
+// Parse parses an amount such as "-1,234.56", "12" or "+.5". Thousands
+// separators are ignored, and digits beyond the second decimal place are
+// rounded half away from zero.
+func Parse(value string) (Amount, error) {
+	s := strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+	if s == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" || !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+
+	var units int64
+	if whole != "" {
+		n, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || n > math.MaxInt64/100 {
+			return 0, fmt.Errorf("amount %q out of range", value)
+		}
+		units = n * 100
+	}
+
+	// Pad or trim the fraction to cents, remembering the first dropped digit
+	roundUp := len(frac) > 2 && frac[2] >= '5'
+	frac = (frac + "00")[:2]
+	cents, _ := strconv.ParseInt(frac, 10, 64)
+	units += cents
+	if roundUp {
+		units++
+	}
+	if units < 0 {
+		return 0, fmt.Errorf("amount %q out of range", value)
+	}
+
+	if negative {
+		units = -units
+	}
+	return Amount(units), nil
+}
+
+// String formats the amount with exactly 2 decimal places and no thousands
+// separators, e.g. -1234.56
+func (a Amount) String() string {
+	sign := ""
+	u := uint64(a)
+	if a < 0 {
+		sign = "-"
+		u = uint64(-a)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, u/100, u%100)
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
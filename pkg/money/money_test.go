@@ -0,0 +1,70 @@
+package money
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Amount
+	}{
+		{"0", 0},
+		{"0.00", 0},
+		{"-0.00", 0},
+		{"12", 1200},
+		{"-45.23", -4523},
+		{"-1,200.00", -120000},
+		{"1,234,567.89", 123456789},
+		{"+.5", 50},
+		{"5.", 500},
+		{" 10.10 ", 1010},
+		{"0.125", 13},
+		{"-0.125", -13},
+		{"0.124", 12},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.input)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, input := range []string{"", "-", ".", "abc", "1.2.3", "1e5", "--5", "12a", "99999999999999999999"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) should fail", input)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := map[Amount]string{
+		0:         "0.00",
+		5:         "0.05",
+		-5:        "-0.05",
+		-4523:     "-45.23",
+		123456789: "1234567.89",
+		-120000:   "-1200.00",
+	}
+	for amount, want := range tests {
+		if got := amount.String(); got != want {
+			t.Errorf("Amount(%d).String() = %q, want %q", int64(amount), got, want)
+		}
+	}
+}
+
+func TestSumIsExact(t *testing.T) {
+	// 0.1 summed as float64 drifts away from 1000.00 after 10,000 additions
+	var total Amount
+	step, _ := Parse("0.10")
+	for i := 0; i < 10000; i++ {
+		total += step
+	}
+	if total.String() != "1000.00" {
+		t.Errorf("total = %s, want 1000.00", total)
+	}
+}
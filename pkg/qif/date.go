@@ -0,0 +1,74 @@
+package qif
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultPivotYear is the first year of the 100-year window that two-digit
+// years are placed in: with 1950, '49 is 2049 and /50 is 1950
+const DefaultPivotYear = 1950
+
+// DateOptions controls how the D lines of a QIF file are read
+type DateOptions struct {
+	PivotYear int  // First year of the two-digit year window; 0 means DefaultPivotYear
+	DayFirst  bool // Dates are written DD/MM rather than Quicken's MM/DD
+}
+
+// qifDateRegex matches the date styles Quicken and banks write: 1/5'23,
+// 1/ 5' 2, 01/05/98, 1/5/1998, 05.01.1998, 01-05-98 and 1998-01-05
+var qifDateRegex = regexp.MustCompile(`^\s*(\d{1,4})\s*[/.\-]\s*(\d{1,2})\s*['/.\-]\s*(\d{1,4})\s*$`)
+
+// ParseDate parses the value of a QIF D line
+func ParseDate(value string, opts DateOptions) (time.Time, error) {
+	m := qifDateRegex.FindStringSubmatch(value)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+
+	var yearText, monthText, dayText string
+	switch {
+	case len(m[1]) == 4:
+		// ISO order, e.g. 1998-01-05
+		yearText, monthText, dayText = m[1], m[2], m[3]
+	case len(m[1]) > 2:
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	case opts.DayFirst:
+		dayText, monthText, yearText = m[1], m[2], m[3]
+	default:
+		monthText, dayText, yearText = m[1], m[2], m[3]
+	}
+
+	month, _ := strconv.Atoi(monthText)
+	day, _ := strconv.Atoi(dayText)
+	year, _ := strconv.Atoi(yearText)
+
+	switch len(yearText) {
+	case 1, 2:
+		year = expandYear(year, opts.PivotYear)
+	case 4:
+	default:
+		return time.Time{}, fmt.Errorf("invalid year in date %q", value)
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	// time.Date normalizes out-of-range values, so 2/30 would become 3/2
+	if date.Month() != time.Month(month) || date.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return date, nil
+}
+
+// expandYear places a two-digit year in the 100-year window starting at pivot
+func expandYear(year, pivot int) int {
+	if pivot == 0 {
+		pivot = DefaultPivotYear
+	}
+	year += pivot - pivot%100
+	if year < pivot {
+		year += 100
+	}
+	return year
+}
@@ -0,0 +1,51 @@
+package qif
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  DateOptions
+		want  string
+	}{
+		{name: "apostrophe two-digit year", input: "1/5'23", want: "2023-01-05"},
+		{name: "apostrophe with spaces", input: "1/ 5' 2", want: "2002-01-05"},
+		{name: "slash two-digit year", input: "01/05/98", want: "1998-01-05"},
+		{name: "slash four-digit year", input: "1/5/1998", want: "1998-01-05"},
+		{name: "leading space", input: " 12/31/94", want: "1994-12-31"},
+		{name: "dashes", input: "01-05-98", want: "1998-01-05"},
+		{name: "iso", input: "1998-01-05", want: "1998-01-05"},
+		{name: "day first", input: "05.01.1998", opts: DateOptions{DayFirst: true}, want: "1998-01-05"},
+		{name: "day first apostrophe", input: "31/12'99", opts: DateOptions{DayFirst: true}, want: "1999-12-31"},
+		{name: "pivot below window", input: "1/5/49", want: "2049-01-05"},
+		{name: "custom pivot", input: "1/5/30", opts: DateOptions{PivotYear: 1931}, want: "2030-01-05"},
+		{name: "custom pivot inside window", input: "1/5/31", opts: DateOptions{PivotYear: 1931}, want: "1931-01-05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDate(tt.input, tt.opts)
+			if err != nil {
+				t.Fatalf("ParseDate(%q) error = %v", tt.input, err)
+			}
+			if got.Format("2006-01-02") != tt.want {
+				t.Errorf("ParseDate(%q) = %s, want %s", tt.input, got.Format("2006-01-02"), tt.want)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("ParseDate(%q) location = %v, want UTC", tt.input, got.Location())
+			}
+		})
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	for _, input := range []string{"", "garbage", "2/30'23", "13/1'23", "1/5'123", "123/5/98"} {
+		if _, err := ParseDate(input, DateOptions{}); err == nil {
+			t.Errorf("ParseDate(%q) should fail", input)
+		}
+	}
+}
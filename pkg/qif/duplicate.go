@@ -0,0 +1,151 @@
+package qif
+
+import (
+	"crypto/sha256"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"qifutil/pkg/money"
+)
+
+// DuplicateGroup is a set of transactions sharing a date, amount and
+// normalized payee
+type DuplicateGroup struct {
+	Accounts []string // Accounts holding the transactions, in order of first appearance
+	Date     time.Time
+	Payee    string // Payee as written in the first transaction of the group
+	Amount   money.Amount
+	Count    int // Transactions in the group
+	Exact    int // Transactions identical to an earlier one in the same account
+}
+
+// duplicateKey identifies a group. The account is left blank when
+// duplicates are detected across accounts.
+type duplicateKey struct {
+	account string
+	date    time.Time
+	payee   string
+	amount  money.Amount
+}
+
+// DuplicateDetector groups transactions on date, amount and normalized payee
+// to find the same transaction downloaded more than once.
+//
+// A register is contiguous in a QIF file, so unless duplicates are detected
+// across accounts, only the current account's transactions are kept: when
+// the account changes, everything but the groups found so far is dropped
+// and memory stays flat however large the file.
+type DuplicateDetector struct {
+	acrossAccounts bool
+	account        string // Account of the last transaction added
+	groups         map[duplicateKey]*DuplicateGroup
+	order          []*DuplicateGroup
+	seen           map[[sha256.Size]byte]bool // Hash of every transaction, field by field
+}
+
+// NewDuplicateDetector returns an empty DuplicateDetector. With
+// acrossAccounts set, transactions in different accounts can be grouped.
+func NewDuplicateDetector(acrossAccounts bool) *DuplicateDetector {
+	return &DuplicateDetector{
+		acrossAccounts: acrossAccounts,
+		groups:         make(map[duplicateKey]*DuplicateGroup),
+		seen:           make(map[[sha256.Size]byte]bool),
+	}
+}
+
+// Add records a transaction and reports whether it is an exact duplicate:
+// identical in every field to a transaction added earlier for the same account
+func (d *DuplicateDetector) Add(account string, t *Transaction) bool {
+	amount, err := money.Parse(t.Amount)
+	if err != nil {
+		return false
+	}
+
+	if !d.acrossAccounts && account != d.account {
+		d.nextAccount()
+	}
+	d.account = account
+
+	key := duplicateKey{date: t.Date, payee: NormalizePayee(t.Payee), amount: amount}
+	if !d.acrossAccounts {
+		key.account = account
+	}
+	group := d.groups[key]
+	if group == nil {
+		group = &DuplicateGroup{Date: t.Date, Payee: t.Payee, Amount: amount}
+		d.groups[key] = group
+		d.order = append(d.order, group)
+	}
+	group.Count++
+	if !containsAccount(group.Accounts, account) {
+		group.Accounts = append(group.Accounts, account)
+	}
+
+	signature := sha256.Sum256([]byte(transactionSignature(account, t)))
+	if d.seen[signature] {
+		group.Exact++
+		return true
+	}
+	d.seen[signature] = true
+	return false
+}
+
+// nextAccount forgets the previous account's transactions, keeping only the
+// groups that hold more than one
+func (d *DuplicateDetector) nextAccount() {
+	kept := d.order[:0]
+	for _, group := range d.order {
+		if group.Count > 1 {
+			kept = append(kept, group)
+		}
+	}
+	clear(d.order[len(kept):])
+	d.order = kept
+	d.groups = make(map[duplicateKey]*DuplicateGroup)
+	d.seen = make(map[[sha256.Size]byte]bool)
+}
+
+// Groups returns every group with more than one transaction, by date
+func (d *DuplicateDetector) Groups() []DuplicateGroup {
+	var groups []DuplicateGroup
+	for _, group := range d.order {
+		if group.Count > 1 {
+			groups = append(groups, *group)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Date.Before(groups[j].Date)
+	})
+	return groups
+}
+
+// NormalizePayee reduces a payee to upper-case letters and digits separated
+// by single spaces, so "Whole Foods, Inc." and "WHOLE FOODS INC" compare equal
+func NormalizePayee(payee string) string {
+	words := strings.FieldsFunc(strings.ToUpper(payee), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// transactionSignature joins every field of a transaction into one string
+func transactionSignature(account string, t *Transaction) string {
+	fields := []string{account, t.Date.Format("2006-01-02"), t.Amount, t.Cleared, t.Number, t.Payee, t.Memo, t.Category}
+	fields = append(fields, t.Address...)
+	for _, split := range t.Splits {
+		fields = append(fields, split.Category, split.Memo, split.Amount, split.Percent)
+	}
+	return strings.Join(fields, "\x00")
+}
+
+// containsAccount reports whether accounts includes account
+func containsAccount(accounts []string, account string) bool {
+	for _, a := range accounts {
+		if a == account {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package qif
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizePayee(t *testing.T) {
+	tests := []struct {
+		payee string
+		want  string
+	}{
+		{"Whole Foods, Inc.", "WHOLE FOODS INC"},
+		{"  WHOLE   FOODS INC ", "WHOLE FOODS INC"},
+		{"AMAZON.COM*MK1234", "AMAZON COM MK1234"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizePayee(tt.payee); got != tt.want {
+			t.Errorf("NormalizePayee(%q) = %q, want %q", tt.payee, got, tt.want)
+		}
+	}
+}
+
+func TestDuplicateDetector(t *testing.T) {
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	d := NewDuplicateDetector(false)
+
+	first := &Transaction{Date: day, Amount: "-45.23", Payee: "Whole Foods", Memo: "Groceries"}
+	if d.Add("Checking", first) {
+		t.Error("First transaction reported as a duplicate")
+	}
+	// Same transaction downloaded twice
+	if !d.Add("Checking", &Transaction{Date: day, Amount: "-45.23", Payee: "Whole Foods", Memo: "Groceries"}) {
+		t.Error("Identical transaction not reported as an exact duplicate")
+	}
+	// Same date, amount and payee but a different memo: grouped, not exact
+	if d.Add("Checking", &Transaction{Date: day, Amount: "-45.23", Payee: "WHOLE FOODS", Memo: "Lunch"}) {
+		t.Error("Transaction with a different memo reported as an exact duplicate")
+	}
+	// Identical transaction in another account
+	if d.Add("Visa", &Transaction{Date: day, Amount: "-45.23", Payee: "Whole Foods", Memo: "Groceries"}) {
+		t.Error("Transaction in another account reported as an exact duplicate")
+	}
+	d.Add("Checking", &Transaction{Date: day, Amount: "-12.00", Payee: "Whole Foods"})
+
+	groups := d.Groups()
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d: %+v", len(groups), groups)
+	}
+	group := groups[0]
+	if group.Count != 3 || group.Exact != 1 || group.Payee != "Whole Foods" || group.Amount != -4523 {
+		t.Errorf("Unexpected group: %+v", group)
+	}
+	if len(group.Accounts) != 1 || group.Accounts[0] != "Checking" {
+		t.Errorf("Expected group in Checking, got %v", group.Accounts)
+	}
+}
+
+func TestDuplicateDetectorAcrossAccounts(t *testing.T) {
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	d := NewDuplicateDetector(true)
+
+	d.Add("Checking", &Transaction{Date: day, Amount: "-45.23", Payee: "Whole Foods"})
+	if d.Add("Visa", &Transaction{Date: day, Amount: "-45.23", Payee: "Whole Foods"}) {
+		t.Error("Transaction in another account reported as an exact duplicate")
+	}
+
+	groups := d.Groups()
+	if len(groups) != 1 || groups[0].Count != 2 || groups[0].Exact != 0 {
+		t.Fatalf("Unexpected groups: %+v", groups)
+	}
+	if len(groups[0].Accounts) != 2 {
+		t.Errorf("Expected both accounts in the group, got %v", groups[0].Accounts)
+	}
+}
+
+func TestDuplicateDetectorForgetsPreviousAccount(t *testing.T) {
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	d := NewDuplicateDetector(false)
+
+	d.Add("Checking", &Transaction{Date: day, Amount: "-45.23", Payee: "Whole Foods"})
+	d.Add("Checking", &Transaction{Date: day, Amount: "-45.23", Payee: "Whole Foods"})
+	d.Add("Checking", &Transaction{Date: day, Amount: "-12.00", Payee: "Coffee Shop"})
+	d.Add("Visa", &Transaction{Date: day, Amount: "-8.00", Payee: "Parking"})
+
+	// Only the Visa transaction is still held, and the Checking group is kept
+	if len(d.seen) != 1 || len(d.groups) != 1 || len(d.order) != 2 {
+		t.Errorf("Expected Checking's transactions to be dropped, got %d seen, %d groups, %d in order", len(d.seen), len(d.groups), len(d.order))
+	}
+	groups := d.Groups()
+	if len(groups) != 1 || groups[0].Count != 2 || groups[0].Exact != 1 {
+		t.Errorf("Unexpected groups: %+v", groups)
+	}
+}
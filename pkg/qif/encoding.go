@@ -0,0 +1,107 @@
+package qif
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Input encodings understood by Options.Encoding
+const (
+	EncodingAuto        = "auto"         // UTF-8 until a line isn't valid UTF-8, then Windows-1252
+	EncodingUTF8        = "utf-8"        // Invalid bytes become U+FFFD
+	EncodingWindows1252 = "windows-1252" // What Quicken for Windows writes
+	EncodingLatin1      = "iso-8859-1"
+)
+
+// encodingAliases maps the accepted spellings of each encoding to its name
+var encodingAliases = map[string]string{
+	"":             EncodingAuto,
+	"auto":         EncodingAuto,
+	"utf-8":        EncodingUTF8,
+	"utf8":         EncodingUTF8,
+	"windows-1252": EncodingWindows1252,
+	"windows1252":  EncodingWindows1252,
+	"cp1252":       EncodingWindows1252,
+	"iso-8859-1":   EncodingLatin1,
+	"iso8859-1":    EncodingLatin1,
+	"latin-1":      EncodingLatin1,
+	"latin1":       EncodingLatin1,
+}
+
+// windows1252 holds the characters Windows-1252 places at 0x80-0x9F, where
+// Latin-1 has control codes. Unassigned positions keep their Latin-1 value.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+// utf8BOM is the byte order mark some editors write at the start of a UTF-8 file
+const utf8BOM = "\xef\xbb\xbf"
+
+// NormalizeEncoding returns the canonical name of an input encoding, e.g.
+// "windows-1252" for "CP1252"
+func NormalizeEncoding(name string) (string, error) {
+	canonical, ok := encodingAliases[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return "", fmt.Errorf("unsupported input encoding %q (use auto, utf-8, windows-1252 or iso-8859-1)", name)
+	}
+	return canonical, nil
+}
+
+// decoderFor returns a function that transcodes one line of input to UTF-8.
+// The auto decoder keeps state, so each stream needs its own.
+func decoderFor(encoding string) (func(string) string, error) {
+	canonical, err := NormalizeEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	switch canonical {
+	case EncodingUTF8:
+		return func(line string) string { return strings.ToValidUTF8(line, "�") }, nil
+	case EncodingWindows1252:
+		return decodeWindows1252, nil
+	case EncodingLatin1:
+		return decodeLatin1, nil
+	}
+
+	// Auto-detect: lines are kept as UTF-8 until one isn't valid UTF-8.
+	// The file is then taken to be Windows-1252, a superset of the printable
+	// Latin-1 range, for the rest of the stream, so a later line whose bytes
+	// happen to form valid UTF-8 (such as "Ã©") is transcoded like the rest.
+	windows := false
+	return func(line string) string {
+		if !windows && utf8.ValidString(line) {
+			return line
+		}
+		windows = true
+		return decodeWindows1252(line)
+	}, nil
+}
+
+func decodeWindows1252(line string) string {
+	var b strings.Builder
+	b.Grow(len(line))
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+		case c < 0xA0:
+			b.WriteRune(windows1252[c-0x80])
+		default:
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+func decodeLatin1(line string) string {
+	var b strings.Builder
+	b.Grow(len(line))
+	for i := 0; i < len(line); i++ {
+		b.WriteRune(rune(line[i]))
+	}
+	return b.String()
+}
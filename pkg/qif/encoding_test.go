@@ -0,0 +1,69 @@
+package qif
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecoders(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		input    string
+		want     string
+	}{
+		{name: "auto keeps utf-8", encoding: "auto", input: "PCafé", want: "PCafé"},
+		{name: "auto falls back to windows-1252", encoding: "", input: "PCaf\xe9 \x80", want: "PCafé €"},
+		{name: "windows-1252 quotes", encoding: "CP1252", input: "M\x93quoted\x94", want: "M“quoted”"},
+		{name: "latin-1", encoding: "latin1", input: "PM\xfcller", want: "PMüller"},
+		{name: "utf-8 replaces invalid bytes", encoding: "UTF-8", input: "PM\xfcller", want: "PM�ller"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decode, err := decoderFor(tt.encoding)
+			if err != nil {
+				t.Fatalf("decoderFor(%q) error = %v", tt.encoding, err)
+			}
+			if got := decode(tt.input); got != tt.want {
+				t.Errorf("decode(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	// Once a line isn't UTF-8, the rest of the file is Windows-1252 too
+	decode, _ := decoderFor(EncodingAuto)
+	for _, tt := range []struct{ input, want string }{
+		{"PCaf\xc3\xa9", "PCafé"},
+		{"PM\xfcller", "PMüller"},
+		{"M\xc3\xa9", "MÃ©"},
+	} {
+		if got := decode(tt.input); got != tt.want {
+			t.Errorf("auto decode(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	if _, err := NormalizeEncoding("ebcdic"); err == nil {
+		t.Error("NormalizeEncoding should reject unknown encodings")
+	}
+}
+
+func TestParseWindows1252(t *testing.T) {
+	input := "\xef\xbb\xbf!Type:Cat\nNCaf\xe9s\n^\n!Account\nNM\xfcller Checking\nTBank\n^\n!Type:Bank\nD1/5'23\nT-4.50\nPCaf\xe9 M\xfcller\n^\n"
+
+	f, err := Parse(strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	// The BOM doesn't hide the first header
+	if len(f.Categories) != 1 || f.Categories[0].Name != "Cafés" {
+		t.Errorf("Unexpected categories %+v", f.Categories)
+	}
+	account := f.Account("Müller Checking")
+	if account == nil || account.Transactions[0].Payee != "Café Müller" {
+		t.Fatalf("Unexpected accounts %+v", f.Accounts)
+	}
+
+	if _, err := Parse(strings.NewReader(input), Options{Encoding: "ebcdic"}); err == nil {
+		t.Error("Parse should fail with an unknown encoding")
+	}
+}
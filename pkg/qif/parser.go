@@ -0,0 +1,427 @@
+package qif
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"qifutil/pkg/money"
+)
+
+// registerTypes are the !Type sections that hold an account's transactions
+var registerTypes = map[string]bool{
+	"bank":    true,
+	"cash":    true,
+	"ccard":   true,
+	"invst":   true,
+	"oth a":   true,
+	"oth l":   true,
+	"invoice": true,
+}
+
+// Options controls how a QIF file is interpreted
+type Options struct {
+	Date DateOptions
+
+	// Encoding is the character encoding of the input: auto (the default),
+	// utf-8, windows-1252 or iso-8859-1. Text is always returned as UTF-8.
+	Encoding string
+
+	// AccountName names the register of a file with no !Account header,
+	// as most bank downloads are. Such registers are skipped if it is empty.
+	AccountName string
+}
+
+// ParseFile reads and parses the QIF file at path
+func ParseFile(path string, opts Options) (*File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file, opts)
+}
+
+// Parse reads a QIF document and returns its accounts, lists and transactions
+func Parse(r io.Reader, opts Options) (*File, error) {
+	reader := NewReader(r, opts)
+	f := &File{}
+
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case entry.Transaction != nil:
+			account := f.addAccount(entry.Account.Name, entry.Account.Type)
+			account.Transactions = append(account.Transactions, *entry.Transaction)
+		case entry.Investment != nil:
+			account := f.addAccount(entry.Account.Name, entry.Account.Type)
+			account.Investments = append(account.Investments, *entry.Investment)
+		case entry.Account != nil:
+			f.addAccount(entry.Account.Name, entry.Account.Type)
+		case entry.Category != nil:
+			f.Categories = append(f.Categories, *entry.Category)
+		case entry.Class != nil:
+			f.Classes = append(f.Classes, *entry.Class)
+		case entry.Tag != nil:
+			f.Tags = append(f.Tags, *entry.Tag)
+		case entry.Security != nil:
+			f.Securities = append(f.Securities, *entry.Security)
+		}
+	}
+
+	// An account's balance may be stated after its register was read
+	for _, account := range f.Accounts {
+		if src := reader.accounts[account.Name]; src != nil {
+			account.Balance = src.Balance
+			account.BalanceDate = src.BalanceDate
+		}
+	}
+
+	return f, nil
+}
+
+// Entry is one item read from a QIF stream. At most one of Transaction,
+// Investment, Category, Class, Tag and Security is set; an entry with only
+// Account set marks the start of that account's register.
+type Entry struct {
+	Account     *Account // Register the entry belongs to; its Transactions and Investments are always empty
+	Transaction *Transaction
+	Investment  *InvestmentTransaction
+	Category    *Category
+	Class       *Class
+	Tag         *Tag
+	Security    *Security
+}
+
+// Reader reads a QIF stream one entry at a time, so files of any size can
+// be processed without holding every transaction in memory
+type Reader struct {
+	rr          *recordReader
+	err         error // Error returned by every call to Next, e.g. an unknown encoding
+	opts        Options
+	section     string              // Lower-cased header of the current section, e.g. "type:bank"
+	header      string              // Header of the current section as written in the file
+	pendingName string              // Account named by the most recent !Account record
+	register    *Account            // Account whose register is being read, if any
+	accounts    map[string]*Account // Accounts seen so far, by name
+	stated      map[string]*Account // Balances stated by !Account records, by name
+	rest        *record             // Rest of a record split at a repeated key field, read next
+	problems    []Problem
+}
+
+// NewReader returns a Reader that parses QIF data from r
+func NewReader(r io.Reader, opts Options) *Reader {
+	decode, err := decoderFor(opts.Encoding)
+	return &Reader{
+		rr:       newRecordReader(r, decode),
+		err:      err,
+		opts:     opts,
+		accounts: make(map[string]*Account),
+		stated:   make(map[string]*Account),
+	}
+}
+
+// Problems returns the structural problems found in the entries read so far
+func (r *Reader) Problems() []Problem {
+	return r.problems
+}
+
+// Next returns the next entry, or io.EOF when the input is exhausted
+func (r *Reader) Next() (*Entry, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for {
+		rec := r.rest
+		r.rest = nil
+		if rec == nil {
+			var err error
+			rec, err = r.rr.next()
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read QIF data: %w", err)
+			}
+		}
+
+		if rec.Header != "" {
+			name := strings.ToLower(strings.TrimPrefix(rec.Header, "!"))
+			if strings.HasPrefix(name, "option:") || strings.HasPrefix(name, "clear:") {
+				// AutoSwitch markers don't start a new section
+				continue
+			}
+			r.section = name
+			r.header = rec.Header
+			r.register = nil
+
+			typ, isType := strings.CutPrefix(r.section, "type:")
+			accountName := r.pendingName
+			if accountName == "" {
+				accountName = r.opts.AccountName
+			}
+			if isType && registerTypes[strings.TrimSpace(typ)] && accountName != "" {
+				r.register = r.account(accountName, strings.TrimSpace(rec.Header[len("!Type:"):]))
+				return &Entry{Account: r.register}, nil
+			}
+			if !knownSection(r.section) {
+				r.problem(rec.Line, SeverityInfo, ProblemUnsupported, "",
+					fmt.Sprintf("section %s is not supported; its records are skipped", rec.Header), rec.Header)
+			}
+			continue
+		}
+
+		// Without its ^, a record runs into the next one; a second D in a
+		// register or N in a list starts that next record
+		if key := keyField(r.section); key != 0 {
+			r.rest = rec.splitAt(key)
+		}
+
+		account := ""
+		if r.register != nil {
+			account = r.register.Name
+		}
+		switch {
+		case r.rest != nil:
+			// The text shows both records, so the place the ^ is missing can be found
+			next := &record{Fields: r.rest.Fields}
+			next.splitAt(keyField(r.section))
+			last := next.Fields[len(next.Fields)-1].Line
+			r.problem(rec.Line, SeverityError, ProblemMissingTerminator, account,
+				fmt.Sprintf("record has no ^ line before line %d, where another %s starts; lines %d-%d are read as the next record", r.rest.Line, string(keyField(r.section)), r.rest.Line, last),
+				rec.Text()+"\n"+next.Text())
+		case !rec.Terminated:
+			r.problem(rec.Line, SeverityWarning, ProblemUnterminated, account, "record is not terminated by a ^ line", rec.Text())
+		}
+
+		switch {
+		case r.section == "":
+			r.problem(rec.Line, SeverityError, ProblemSkippedRecord, "", "record skipped: it comes before any !Type or !Account header", rec.Text())
+		case r.section == "account":
+			r.checkFields(rec, r.section, "")
+			r.pendingName = rec.Get('N')
+			r.stateBalance(rec)
+		case r.register != nil && r.section == "type:invst":
+			r.checkFields(rec, r.section, account)
+			t, ok := parseInvestment(rec, r.opts.Date)
+			if !ok {
+				r.problemInvalidDate(rec, account)
+				continue
+			}
+			if t.Amount != "" {
+				if _, err := money.Parse(t.Amount); err != nil {
+					r.problem(rec.Line, SeverityError, ProblemInvalidAmount, account,
+						fmt.Sprintf("unreadable amount %q on %s", t.Amount, t.Date.Format("2006-01-02")), rec.Text())
+				}
+			}
+			return &Entry{Account: r.register, Investment: &t}, nil
+		case r.register != nil:
+			r.checkFields(rec, "register", account)
+			t, ok := parseTransaction(rec, r.opts.Date)
+			if !ok {
+				r.problemInvalidDate(rec, account)
+				continue
+			}
+			r.checkAmounts(rec, &t, account)
+			return &Entry{Account: r.register, Transaction: &t}, nil
+		case isRegister(r.section):
+			r.problem(rec.Line, SeverityError, ProblemSkippedRecord, "",
+				fmt.Sprintf("transaction skipped: the %s register has no !Account name", r.header), rec.Text())
+		case r.section == "type:cat":
+			r.checkFields(rec, r.section, "")
+			return &Entry{Category: &Category{
+				Name:        rec.Get('N'),
+				Description: rec.Get('D'),
+				TaxRelated:  rec.Has('T'),
+				Income:      rec.Has('I'),
+			}}, nil
+		case r.section == "type:class":
+			r.checkFields(rec, r.section, "")
+			return &Entry{Class: &Class{Name: rec.Get('N'), Description: rec.Get('D')}}, nil
+		case r.section == "type:tag":
+			r.checkFields(rec, r.section, "")
+			return &Entry{Tag: &Tag{Name: rec.Get('N'), Description: rec.Get('D')}}, nil
+		case r.section == "type:security":
+			r.checkFields(rec, r.section, "")
+			return &Entry{Security: &Security{Name: rec.Get('N'), Symbol: rec.Get('S'), Type: rec.Get('T')}}, nil
+		}
+	}
+}
+
+// problemInvalidDate records a register record skipped for its date
+func (r *Reader) problemInvalidDate(rec *record, account string) {
+	message := "transaction skipped: no date"
+	if rec.Has('D') {
+		message = fmt.Sprintf("transaction skipped: unreadable date %q", rec.Get('D'))
+	}
+	r.problem(rec.Line, SeverityError, ProblemInvalidDate, account, message, rec.Text())
+}
+
+// keyField returns the field code every record of a lower-cased section
+// has exactly once, or 0 for sections without one
+func keyField(section string) byte {
+	switch {
+	case isRegister(section):
+		return 'D'
+	case section == "account", section == "type:cat", section == "type:class", section == "type:tag", section == "type:security":
+		return 'N'
+	}
+	return 0
+}
+
+// isRegister reports whether a lower-cased section holds an account's transactions
+func isRegister(section string) bool {
+	typ, isType := strings.CutPrefix(section, "type:")
+	return isType && registerTypes[strings.TrimSpace(typ)]
+}
+
+// knownSection reports whether the reader understands a lower-cased section
+func knownSection(section string) bool {
+	switch section {
+	case "account", "type:cat", "type:class", "type:tag", "type:security":
+		return true
+	}
+	return isRegister(section)
+}
+
+// account returns the named account, creating it on its first register
+func (r *Reader) account(name, accountType string) *Account {
+	if account, ok := r.accounts[name]; ok {
+		return account
+	}
+	account := &Account{Name: name, Type: accountType}
+	if stated := r.stated[name]; stated != nil {
+		account.Balance = stated.Balance
+		account.BalanceDate = stated.BalanceDate
+	}
+	r.accounts[name] = account
+	return account
+}
+
+// stateBalance keeps the balance an !Account record states. Quicken writes
+// it in the account list at the top of a full export; some exporters use B
+// instead of $.
+func (r *Reader) stateBalance(rec *record) {
+	balance := rec.Get('$')
+	if !rec.Has('$') {
+		balance = rec.Get('B')
+	}
+	if balance == "" {
+		return
+	}
+	stated := &Account{Balance: balance}
+	if rec.Has('/') {
+		if date, err := ParseDate(rec.Get('/'), r.opts.Date); err == nil {
+			stated.BalanceDate = date
+		}
+	}
+
+	name := rec.Get('N')
+	r.stated[name] = stated
+	if account := r.accounts[name]; account != nil {
+		account.Balance = stated.Balance
+		account.BalanceDate = stated.BalanceDate
+	}
+}
+
+// addAccount returns the named account, adding it to the file if this is its first register
+func (f *File) addAccount(name, accountType string) *Account {
+	if account := f.Account(name); account != nil {
+		return account
+	}
+	account := &Account{Name: name, Type: accountType}
+	f.Accounts = append(f.Accounts, account)
+	return account
+}
+
+// parseTransaction builds a Transaction from a register record. Records
+// without a readable date are not transactions and are rejected.
+func parseTransaction(rec *record, dateOpts DateOptions) (Transaction, bool) {
+	date, err := ParseDate(rec.Get('D'), dateOpts)
+	if err != nil {
+		return Transaction{}, false
+	}
+
+	// T is the transaction amount; some exporters only write U
+	amount := rec.Get('T')
+	if !rec.Has('T') {
+		amount = rec.Get('U')
+	}
+
+	return Transaction{
+		Date:     date,
+		Amount:   amount,
+		Cleared:  rec.Get('C'),
+		Number:   rec.Get('N'),
+		Payee:    rec.Get('P'),
+		Address:  rec.All('A'),
+		Memo:     rec.Get('M'),
+		Category: rec.Get('L'),
+		Splits:   parseSplits(rec),
+	}, true
+}
+
+// parseSplits collects the S, E, $ and % lines of a record. Each S line
+// starts a new split; the lines after it describe that split.
+func parseSplits(rec *record) []Split {
+	var splits []Split
+	for _, f := range rec.Fields {
+		switch f.Code {
+		case 'S', 'E', '$', '%':
+		default:
+			continue
+		}
+		if f.Code == 'S' || len(splits) == 0 {
+			splits = append(splits, Split{})
+		}
+		split := &splits[len(splits)-1]
+		switch f.Code {
+		case 'S':
+			split.Category = f.Value
+		case 'E':
+			split.Memo = f.Value
+		case '$':
+			split.Amount = f.Value
+		case '%':
+			split.Percent = f.Value
+		}
+	}
+	return splits
+}
+
+// parseInvestment builds an InvestmentTransaction from an Invst register record
+func parseInvestment(rec *record, dateOpts DateOptions) (InvestmentTransaction, bool) {
+	date, err := ParseDate(rec.Get('D'), dateOpts)
+	if err != nil {
+		return InvestmentTransaction{}, false
+	}
+
+	amount := rec.Get('T')
+	if !rec.Has('T') {
+		amount = rec.Get('U')
+	}
+
+	return InvestmentTransaction{
+		Date:           date,
+		Action:         rec.Get('N'),
+		Security:       rec.Get('Y'),
+		Price:          rec.Get('I'),
+		Quantity:       rec.Get('Q'),
+		Commission:     rec.Get('O'),
+		Amount:         amount,
+		TransferAmount: rec.Get('$'),
+		Cleared:        rec.Get('C'),
+		Payee:          rec.Get('P'),
+		Memo:           rec.Get('M'),
+		Category:       rec.Get('L'),
+	}, true
+}
@@ -0,0 +1,386 @@
+package qif
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleQIF = `!Type:Cat
+NFood:Groceries
+DGroceries and household
+E
+^
+NIncome:Salary
+T
+I
+^
+!Type:Tag
+NVacation
+DTrips away from home
+^
+!Type:Class
+NBusiness
+^
+!Type:Security
+NVanguard Total Stock
+SVTSAX
+TMutual Fund
+^
+!Account
+NChecking Account
+TBank
+^
+!Type:Bank
+D1/5'23
+U5000.00
+T5000.00
+CX
+PEmployee Payroll
+MMonthly salary deposit
+LIncome:Salary
+^
+D1/15'23
+U-45.23
+T-45.23
+CX
+N1042
+LFood:Groceries/Vacation
+^
+!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D2/ 3'23
+U-1,200.00
+T-1,200.00
+C
+PAirline
+LTravel
+^
+`
+
+func TestParseAccounts(t *testing.T) {
+	f, err := Parse(strings.NewReader(sampleQIF), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(f.Accounts) != 2 {
+		t.Fatalf("Expected 2 accounts, got %d", len(f.Accounts))
+	}
+
+	checking := f.Accounts[0]
+	if checking.Name != "Checking Account" || checking.Type != "Bank" {
+		t.Errorf("Unexpected account %q of type %q", checking.Name, checking.Type)
+	}
+	if len(checking.Transactions) != 2 {
+		t.Fatalf("Expected 2 checking transactions, got %d", len(checking.Transactions))
+	}
+
+	salary := checking.Transactions[0]
+	if !salary.Date.Equal(time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Date = %v, want 2023-01-05", salary.Date)
+	}
+	if salary.Amount != "5000.00" || salary.Payee != "Employee Payroll" || salary.Memo != "Monthly salary deposit" {
+		t.Errorf("Unexpected transaction %+v", salary)
+	}
+	if salary.Cleared != "X" || salary.Category != "Income:Salary" {
+		t.Errorf("Unexpected cleared/category %q/%q", salary.Cleared, salary.Category)
+	}
+
+	// Payee is optional
+	groceries := checking.Transactions[1]
+	if groceries.Payee != "" || groceries.Number != "1042" || groceries.Category != "Food:Groceries/Vacation" {
+		t.Errorf("Unexpected transaction %+v", groceries)
+	}
+
+	visa := f.Account("Visa")
+	if visa == nil {
+		t.Fatal("Account(\"Visa\") returned nil")
+	}
+	if visa.Type != "CCard" || len(visa.Transactions) != 1 {
+		t.Fatalf("Unexpected Visa account %+v", visa)
+	}
+	if !visa.Transactions[0].Date.Equal(time.Date(2023, 2, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Date = %v, want 2023-02-03", visa.Transactions[0].Date)
+	}
+	if visa.Transactions[0].Amount != "-1,200.00" {
+		t.Errorf("Amount = %q, want -1,200.00", visa.Transactions[0].Amount)
+	}
+
+	if f.Account("Savings") != nil {
+		t.Error("Account() should return nil for an unknown account")
+	}
+}
+
+func TestParseLists(t *testing.T) {
+	f, err := Parse(strings.NewReader(sampleQIF), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if len(f.Categories) != 2 {
+		t.Fatalf("Expected 2 categories, got %d", len(f.Categories))
+	}
+	if f.Categories[0].Name != "Food:Groceries" || f.Categories[0].Description != "Groceries and household" || f.Categories[0].Income {
+		t.Errorf("Unexpected category %+v", f.Categories[0])
+	}
+	if !f.Categories[1].Income || !f.Categories[1].TaxRelated {
+		t.Errorf("Expected Income:Salary to be a tax-related income category, got %+v", f.Categories[1])
+	}
+
+	if len(f.Tags) != 1 || f.Tags[0].Name != "Vacation" || f.Tags[0].Description != "Trips away from home" {
+		t.Errorf("Unexpected tags %+v", f.Tags)
+	}
+	if len(f.Classes) != 1 || f.Classes[0].Name != "Business" {
+		t.Errorf("Unexpected classes %+v", f.Classes)
+	}
+	if len(f.Securities) != 1 || f.Securities[0].Symbol != "VTSAX" || f.Securities[0].Type != "Mutual Fund" {
+		t.Errorf("Unexpected securities %+v", f.Securities)
+	}
+}
+
+func TestParseCRLF(t *testing.T) {
+	f, err := Parse(strings.NewReader(strings.ReplaceAll(sampleQIF, "\n", "\r\n")), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(f.Accounts) != 2 || len(f.Accounts[0].Transactions) != 2 {
+		t.Errorf("CRLF input parsed differently: %d accounts", len(f.Accounts))
+	}
+}
+
+func TestParseFieldOrder(t *testing.T) {
+	input := `!Account
+NChecking
+TBank
+^
+!Type:Bank
+PAlaska Airlines
+N1001
+D1/12'23
+MBusiness trip
+T-162.06
+^
+D1/13'23
+U-20.00
+^
+`
+	f, err := Parse(strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	transactions := f.Accounts[0].Transactions
+	if len(transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(transactions))
+	}
+
+	reordered := transactions[0]
+	if reordered.Payee != "Alaska Airlines" || reordered.Number != "1001" || reordered.Memo != "Business trip" || reordered.Amount != "-162.06" {
+		t.Errorf("Unexpected transaction %+v", reordered)
+	}
+
+	// No C, P or L lines, and only a U amount
+	sparse := transactions[1]
+	if sparse.Amount != "-20.00" || sparse.Cleared != "" || sparse.Payee != "" || sparse.Category != "" {
+		t.Errorf("Unexpected transaction %+v", sparse)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	input := "!Account\nNChecking\nTBank\n^\n!Type:Bank\nD4/3'23\nT-150.00\nN2047\nCR\nPCity Water\nA1200 Main Street\nASpringfield, IL 62701\n^\n"
+	f, err := Parse(strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tr := f.Accounts[0].Transactions[0]
+	if len(tr.Address) != 2 || tr.Address[0] != "1200 Main Street" || tr.Address[1] != "Springfield, IL 62701" {
+		t.Errorf("Address = %q", tr.Address)
+	}
+	if tr.Number != "2047" || tr.Cleared != "R" {
+		t.Errorf("Unexpected number/cleared %q/%q", tr.Number, tr.Cleared)
+	}
+}
+
+func TestParseAccountTypes(t *testing.T) {
+	var input strings.Builder
+	types := []string{"Bank", "Cash", "CCard", "Invst", "Oth A", "Oth L", "Invoice"}
+	for _, typ := range types {
+		input.WriteString("!Account\nN" + typ + " Account\nT" + typ + "\n^\n!Type:" + typ + "\nD1/5'23\nT1.00\n^\n")
+	}
+
+	f, err := Parse(strings.NewReader(input.String()), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(f.Accounts) != len(types) {
+		t.Fatalf("Expected %d accounts, got %d", len(types), len(f.Accounts))
+	}
+	for i, typ := range types {
+		if f.Accounts[i].Type != typ {
+			t.Errorf("Account %d type = %q, want %q", i, f.Accounts[i].Type, typ)
+		}
+		if n := len(f.Accounts[i].Transactions) + len(f.Accounts[i].Investments); n != 1 {
+			t.Errorf("Account %q has %d transactions, want 1", f.Accounts[i].Name, n)
+		}
+	}
+}
+
+func TestParseInvestments(t *testing.T) {
+	input := `!Account
+NBrokerage
+TInvst
+^
+!Type:Invst
+D3/1'23
+NBuy
+YVanguard Total Stock Market
+I101.25
+Q10
+O4.95
+T1,017.45
+^
+D4/3'23
+NSell
+YVanguard Total Stock Market
+Q5
+T545.05
+L[Checking Account]
+$545.05
+^
+`
+	f, err := Parse(strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	account := f.Account("Brokerage")
+	if account == nil || account.Type != "Invst" {
+		t.Fatalf("Expected Invst account, got %+v", account)
+	}
+	if len(account.Transactions) != 0 {
+		t.Errorf("Investment records should not be parsed as bank transactions, got %d", len(account.Transactions))
+	}
+	if len(account.Investments) != 2 {
+		t.Fatalf("Expected 2 investment transactions, got %d", len(account.Investments))
+	}
+
+	buy := account.Investments[0]
+	if buy.Action != "Buy" || buy.Security != "Vanguard Total Stock Market" || buy.Price != "101.25" ||
+		buy.Quantity != "10" || buy.Commission != "4.95" || buy.Amount != "1,017.45" {
+		t.Errorf("Unexpected buy %+v", buy)
+	}
+
+	sell := account.Investments[1]
+	if sell.Action != "Sell" || sell.TransferAmount != "545.05" || sell.Category != "[Checking Account]" {
+		t.Errorf("Unexpected sell %+v", sell)
+	}
+}
+
+func TestParseSplits(t *testing.T) {
+	input := `!Account
+NChecking
+TBank
+^
+!Type:Bank
+D3/4'23
+T-100.00
+PTarget
+LFood:Groceries
+SFood:Groceries
+$-60.00
+SHousehold
+EPaper towels
+$-40.00
+%40
+^
+`
+	f, err := Parse(strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	splits := f.Accounts[0].Transactions[0].Splits
+	if len(splits) != 2 {
+		t.Fatalf("Expected 2 splits, got %d", len(splits))
+	}
+	if splits[0].Category != "Food:Groceries" || splits[0].Amount != "-60.00" || splits[0].Memo != "" {
+		t.Errorf("Unexpected first split %+v", splits[0])
+	}
+	if splits[1].Category != "Household" || splits[1].Memo != "Paper towels" || splits[1].Amount != "-40.00" || splits[1].Percent != "40" {
+		t.Errorf("Unexpected second split %+v", splits[1])
+	}
+	if f.Accounts[0].Transactions[0].Category != "Food:Groceries" {
+		t.Errorf("Parent category = %q, want Food:Groceries", f.Accounts[0].Transactions[0].Category)
+	}
+}
+
+func TestReaderStreamsEntries(t *testing.T) {
+	reader := NewReader(strings.NewReader(sampleQIF), Options{})
+
+	var kinds []string
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		switch {
+		case entry.Transaction != nil:
+			kinds = append(kinds, "txn:"+entry.Account.Name)
+		case entry.Account != nil:
+			kinds = append(kinds, "account:"+entry.Account.Name)
+		case entry.Category != nil:
+			kinds = append(kinds, "cat")
+		case entry.Class != nil:
+			kinds = append(kinds, "class")
+		case entry.Tag != nil:
+			kinds = append(kinds, "tag")
+		case entry.Security != nil:
+			kinds = append(kinds, "security")
+		}
+		if entry.Account != nil && len(entry.Account.Transactions) != 0 {
+			t.Errorf("Reader should not accumulate transactions on %q", entry.Account.Name)
+		}
+	}
+
+	want := "cat,cat,tag,class,security,account:Checking Account,txn:Checking Account,txn:Checking Account,account:Visa,txn:Visa"
+	if got := strings.Join(kinds, ","); got != want {
+		t.Errorf("Entries = %s\nwant %s", got, want)
+	}
+}
+
+func TestParseHeaderlessRegister(t *testing.T) {
+	input := "!Type:Bank\nD3/1'23\nT-25.00\nPCorner Bakery\n^\n"
+
+	f, err := Parse(strings.NewReader(input), Options{})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(f.Accounts) != 0 {
+		t.Errorf("Expected no accounts without an AccountName, got %d", len(f.Accounts))
+	}
+
+	f, err = Parse(strings.NewReader(input), Options{AccountName: "Downloads"})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(f.Accounts) != 1 || f.Accounts[0].Name != "Downloads" || f.Accounts[0].Type != "Bank" {
+		t.Fatalf("Unexpected accounts %+v", f.Accounts)
+	}
+	if len(f.Accounts[0].Transactions) != 1 || f.Accounts[0].Transactions[0].Payee != "Corner Bakery" {
+		t.Errorf("Unexpected transactions %+v", f.Accounts[0].Transactions)
+	}
+
+	// An !Account header still takes precedence
+	f, _ = Parse(strings.NewReader(sampleQIF), Options{AccountName: "Downloads"})
+	if f.Account("Downloads") != nil {
+		t.Error("AccountName should only apply to registers without an !Account header")
+	}
+}
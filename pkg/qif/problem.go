@@ -0,0 +1,145 @@
+package qif
+
+import (
+	"fmt"
+	"strings"
+
+	"qifutil/pkg/money"
+)
+
+// Severity ranks how serious a Problem is
+type Severity int
+
+const (
+	SeverityInfo    Severity = iota // Unusual but harmless, e.g. an unsupported section
+	SeverityWarning                 // Data may be incomplete or wrong
+	SeverityError                   // Data was lost or can't be read
+)
+
+// String returns the lower-case name of the severity
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// MarshalText writes the severity by name, e.g. in JSON reports
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses info, warning or error, ignoring case
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "info":
+		return SeverityInfo, nil
+	case "warning", "warn":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q (use info, warning or error)", name)
+}
+
+// Problem codes reported by Reader
+const (
+	ProblemUnterminated      = "unterminated-record" // Record not closed by a ^ line
+	ProblemMissingTerminator = "missing-terminator"  // Record running into the next one, found by a repeated D or N line
+	ProblemUnknownField      = "unknown-field"       // Field code not used by the section
+	ProblemInvalidDate       = "invalid-date"        // Missing or unreadable D line; the record is skipped
+	ProblemInvalidAmount     = "invalid-amount"      // Unreadable T, U or $ line
+	ProblemSkippedRecord     = "skipped-record"      // Record the parser can't place, e.g. a register with no account
+	ProblemSplitMismatch     = "split-mismatch"      // Split amounts don't add up to the transaction amount
+	ProblemUnsupported       = "unsupported-section" // Section such as !Type:Memorized that isn't read
+)
+
+// Problem is something wrong with the structure of a QIF file, found while reading it
+type Problem struct {
+	Line     int      `json:"line"` // 1-based line number
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Account  string   `json:"account,omitempty"` // Register holding the record, if any
+	Message  string   `json:"message"`
+	Text     string   `json:"text,omitempty"` // The record or line as written in the file
+}
+
+// String formats the problem as "line 12: message"
+func (p Problem) String() string {
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+// sectionFields lists the field codes each section uses; sections not
+// listed aren't checked for unknown fields
+var sectionFields = map[string]string{
+	"account":       "NTDL/$B",
+	"type:cat":      "NDTIEBR",
+	"type:class":    "ND",
+	"type:tag":      "ND",
+	"type:security": "NSTG",
+	"type:invst":    "DNYIQTUOCPML$",
+	"register":      "DTUCNPAMLSE$%F",
+}
+
+// checkFields reports fields whose code the section doesn't use
+func (r *Reader) checkFields(rec *record, section, account string) {
+	codes, ok := sectionFields[section]
+	if !ok {
+		return
+	}
+	for _, f := range rec.Fields {
+		if strings.IndexByte(codes, f.Code) < 0 {
+			r.problem(f.Line, SeverityWarning, ProblemUnknownField, account,
+				fmt.Sprintf("unknown field %q in %s record", string(f.Code), r.header), f.Raw)
+		}
+	}
+}
+
+// checkAmounts reports unreadable amounts and splits that don't add up to
+// the transaction amount
+func (r *Reader) checkAmounts(rec *record, t *Transaction, account string) {
+	total, err := money.Parse(t.Amount)
+	if err != nil {
+		r.problem(rec.Line, SeverityError, ProblemInvalidAmount, account,
+			fmt.Sprintf("unreadable amount %q on %s", t.Amount, t.Date.Format("2006-01-02")), rec.Text())
+	}
+
+	if len(t.Splits) == 0 {
+		return
+	}
+	var sum money.Amount
+	complete := err == nil
+	for _, f := range rec.Fields {
+		if f.Code != '$' {
+			continue
+		}
+		amount, splitErr := money.Parse(f.Value)
+		if splitErr != nil {
+			r.problem(f.Line, SeverityError, ProblemInvalidAmount, account,
+				fmt.Sprintf("unreadable split amount %q", f.Value), f.Raw)
+			complete = false
+			continue
+		}
+		sum += amount
+	}
+	for _, split := range t.Splits {
+		if split.Amount == "" {
+			// Percentage splits may leave the amount out
+			complete = false
+		}
+	}
+	if complete && sum != total {
+		r.problem(rec.Line, SeverityWarning, ProblemSplitMismatch, account,
+			fmt.Sprintf("splits add up to %s but the transaction amount is %s", sum, total), rec.Text())
+	}
+}
+
+// problem records a Problem found while reading
+func (r *Reader) problem(line int, severity Severity, code, account, message, text string) {
+	r.problems = append(r.problems, Problem{Line: line, Severity: severity, Code: code, Account: account, Message: message, Text: text})
+}
@@ -0,0 +1,180 @@
+package qif
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// readProblems reads input to the end and returns the problems found
+func readProblems(t *testing.T, input string, opts Options) []Problem {
+	t.Helper()
+	reader := NewReader(strings.NewReader(input), opts)
+	for {
+		_, err := reader.Next()
+		if err == io.EOF {
+			return reader.Problems()
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+	}
+}
+
+func TestReaderNoProblems(t *testing.T) {
+	if problems := readProblems(t, sampleQIF, Options{}); len(problems) != 0 {
+		t.Errorf("Expected no problems in the sample, got %v", problems)
+	}
+}
+
+func TestReaderProblems(t *testing.T) {
+	input := strings.Join([]string{
+		"NStray", // 1: before any header
+		"^",
+		"!Account",
+		"NChecking",
+		"TBank",
+		"^",
+		"!Type:Bank",
+		"D13/45'23", // 8: unreadable date
+		"T-10.00",
+		"^",
+		"D1/5'23", // 11: unreadable amount, unknown field
+		"Tabc",
+		"Zmystery",
+		"^",
+		"D1/6'23", // 15: splits don't add up
+		"T-100.00",
+		"SFood",
+		"$-60.00",
+		"SHome",
+		"$-30.00",
+		"^",
+		"!Type:Memorized", // 22: unsupported
+		"PNetflix",
+		"^",
+		"!Type:Bank",
+		"D1/7'23", // 26: unterminated at end of input
+		"T-5.00",
+	}, "\n")
+
+	problems := readProblems(t, input, Options{})
+	want := []struct {
+		line     int
+		code     string
+		severity Severity
+	}{
+		{1, ProblemSkippedRecord, SeverityError},
+		{8, ProblemInvalidDate, SeverityError},
+		{13, ProblemUnknownField, SeverityWarning},
+		{11, ProblemInvalidAmount, SeverityError},
+		{15, ProblemSplitMismatch, SeverityWarning},
+		{22, ProblemUnsupported, SeverityInfo},
+		{26, ProblemUnterminated, SeverityWarning},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("Expected %d problems, got %d: %v", len(want), len(problems), problems)
+	}
+	for i, w := range want {
+		p := problems[i]
+		if p.Line != w.line || p.Code != w.code || p.Severity != w.severity {
+			t.Errorf("Problem %d = %+v, want line %d %s %s", i, p, w.line, w.severity, w.code)
+		}
+	}
+	if problems[1].Account != "Checking" || problems[1].Message != `transaction skipped: unreadable date "13/45'23"` {
+		t.Errorf("Unexpected invalid date problem: %+v", problems[1])
+	}
+	if problems[1].Text != "D13/45'23\nT-10.00" {
+		t.Errorf("Expected the raw record text, got %q", problems[1].Text)
+	}
+}
+
+func TestReaderSplitsRecordsMissingTerminator(t *testing.T) {
+	input := strings.Join([]string{
+		"!Type:Cat",
+		"NFood", // 2: no ^ before the next N
+		"E",
+		"NSalary",
+		"I",
+		"^",
+		"!Account",
+		"NChecking",
+		"TBank",
+		"^",
+		"!Type:Bank",
+		"D1/5'23",
+		"T-10.00",
+		"PFirst",
+		"^",
+		"D1/6'23", // 16: no ^ before the next D
+		"T-20.00",
+		"PSecond",
+		"D1/7'23",
+		"T-30.00",
+		"PThird",
+		"^",
+	}, "\n")
+
+	r := NewReader(strings.NewReader(input), Options{})
+	var categories, payees []string
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			break
+		}
+		if entry.Category != nil {
+			categories = append(categories, entry.Category.Name)
+		}
+		if entry.Transaction != nil {
+			payees = append(payees, entry.Transaction.Payee)
+		}
+	}
+	if strings.Join(categories, ",") != "Food,Salary" {
+		t.Errorf("Expected both categories, got %v", categories)
+	}
+	if strings.Join(payees, ",") != "First,Second,Third" {
+		t.Errorf("Expected all three transactions, got %v", payees)
+	}
+
+	problems := r.Problems()
+	if len(problems) != 2 || problems[0].Line != 2 || problems[1].Line != 16 {
+		t.Fatalf("Expected problems at lines 2 and 16, got %v", problems)
+	}
+	for _, p := range problems {
+		if p.Code != ProblemMissingTerminator || p.Severity != SeverityError {
+			t.Errorf("Expected a missing-terminator error, got %+v", p)
+		}
+	}
+	if problems[1].Message != "record has no ^ line before line 19, where another D starts; lines 19-21 are read as the next record" {
+		t.Errorf("Unexpected message %q", problems[1].Message)
+	}
+	if problems[1].Text != "D1/6'23\nT-20.00\nPSecond\nD1/7'23\nT-30.00\nPThird" {
+		t.Errorf("Expected the text of both records, got %q", problems[1].Text)
+	}
+}
+
+func TestReaderRegisterWithoutAccount(t *testing.T) {
+	input := "!Type:Bank\nD1/5'23\nT-10.00\n^\n"
+
+	problems := readProblems(t, input, Options{})
+	if len(problems) != 1 || problems[0].Code != ProblemSkippedRecord || problems[0].Line != 2 {
+		t.Fatalf("Expected one skipped record on line 2, got %v", problems)
+	}
+
+	// With a default account name the register is read
+	if problems := readProblems(t, input, Options{AccountName: "Download"}); len(problems) != 0 {
+		t.Errorf("Expected no problems with an account name, got %v", problems)
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for name, want := range map[string]Severity{"info": SeverityInfo, "Warning": SeverityWarning, "ERROR": SeverityError} {
+		got, err := ParseSeverity(name)
+		if err != nil || got != want {
+			t.Errorf("ParseSeverity(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Error("ParseSeverity(\"fatal\") should fail")
+	}
+}
@@ -0,0 +1,98 @@
+// Package qif parses Quicken Interchange Format (QIF) files into a typed
+// model shared by every qifutil command.
+package qif
+
+import "time"
+
+// File is the parsed contents of a QIF file
+type File struct {
+	Accounts   []*Account
+	Categories []Category
+	Classes    []Class
+	Tags       []Tag
+	Securities []Security
+}
+
+// Account is an account header together with the transactions in its register
+type Account struct {
+	Name         string
+	Type         string    // Register type from the !Type line: Bank, Cash, CCard, Invst, Oth A, Oth L or Invoice
+	Balance      string    // Balance stated by the $ (or B) line of the !Account record, if any
+	BalanceDate  time.Time // Date of the stated balance from the / line; zero if not given
+	Transactions []Transaction
+	Investments  []InvestmentTransaction // Entries of an Invst register
+}
+
+// Transaction is a single register entry
+type Transaction struct {
+	Date     time.Time
+	Amount   string // Amount as written in the file
+	Cleared  string // Cleared status: blank, c or * (cleared), X or R (reconciled)
+	Number   string // Check or reference number
+	Payee    string
+	Address  []string // A lines, one per line of the payee's address
+	Memo     string
+	Category string // Raw L field, which may carry a /tag suffix
+	Splits   []Split
+}
+
+// InvestmentTransaction is a single entry in an investment (!Type:Invst) register
+type InvestmentTransaction struct {
+	Date           time.Time
+	Action         string // N line: Buy, Sell, Div, ReinvDiv, ShrsIn, ...
+	Security       string // Y line
+	Price          string // I line
+	Quantity       string // Q line, number of shares
+	Commission     string // O line
+	Amount         string // T line, total amount of the transaction
+	TransferAmount string // $ line, amount transferred to or from another account
+	Cleared        string
+	Payee          string
+	Memo           string
+	Category       string // L line, usually a [transfer account]
+}
+
+// Split is one line of a split transaction
+type Split struct {
+	Category string // S line
+	Memo     string // E line
+	Amount   string // $ line
+	Percent  string // % line, only present for percentage splits
+}
+
+// Category is an entry from the !Type:Cat list
+type Category struct {
+	Name        string
+	Description string
+	Income      bool
+	TaxRelated  bool
+}
+
+// Class is an entry from the !Type:Class list
+type Class struct {
+	Name        string
+	Description string
+}
+
+// Tag is an entry from the !Type:Tag list
+type Tag struct {
+	Name        string
+	Description string
+}
+
+// Security is an entry from the !Type:Security list
+type Security struct {
+	Name   string
+	Symbol string
+	Type   string
+}
+
+// Account returns the account with the given name, or nil if there is none
+func (f *File) Account(name string) *Account {
+	for _, account := range f.Accounts {
+		if account.Name == name {
+			return account
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package qif
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// maxLineLength bounds a single line of a QIF file; memos can be long but
+// anything past this is not a QIF file
+const maxLineLength = 1024 * 1024
+
+// field is one line of a record: a single-character code and its value
+type field struct {
+	Code  byte
+	Value string
+	Line  int    // 1-based line number in the file
+	Raw   string // The line as written, after transcoding
+}
+
+// record is either a header line such as !Type:Bank or the field lines
+// of one entry up to its ^ terminator
+type record struct {
+	Header     string
+	Fields     []field
+	Line       int  // 1-based line number of the header or first field
+	Terminated bool // Whether the record ended at a ^ line
+}
+
+// Get returns the value of the first field with the given code
+func (r *record) Get(code byte) string {
+	for _, f := range r.Fields {
+		if f.Code == code {
+			return f.Value
+		}
+	}
+	return ""
+}
+
+// All returns the values of every field with the given code, in file order
+func (r *record) All(code byte) []string {
+	var values []string
+	for _, f := range r.Fields {
+		if f.Code == code {
+			values = append(values, f.Value)
+		}
+	}
+	return values
+}
+
+// Text returns the lines of the record as written in the file
+func (r *record) Text() string {
+	if r.Header != "" {
+		return r.Header
+	}
+	lines := make([]string, len(r.Fields))
+	for i, f := range r.Fields {
+		lines[i] = f.Raw
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Has reports whether the record contains a field with the given code
+func (r *record) Has(code byte) bool {
+	for _, f := range r.Fields {
+		if f.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAt cuts the record before the second field with the given code and
+// returns the fields from there on as a record of its own, or nil if the
+// code appears at most once. A record that repeats the field every entry
+// has, such as the D of a transaction, has lost the ^ that ended it.
+func (r *record) splitAt(code byte) *record {
+	seen := false
+	for i, f := range r.Fields {
+		if f.Code != code {
+			continue
+		}
+		if seen {
+			rest := &record{Fields: r.Fields[i:], Line: f.Line, Terminated: r.Terminated}
+			r.Fields = r.Fields[:i:i]
+			r.Terminated = false
+			return rest
+		}
+		seen = true
+	}
+	return nil
+}
+
+// recordReader splits a QIF stream into headers and records. Fields may
+// appear in any order; a record ends at a ^ line or at the next header.
+type recordReader struct {
+	scanner   *bufio.Scanner
+	decode    func(string) string // Transcodes a line to UTF-8
+	pending   *record             // header read while finishing the previous record
+	firstLine bool
+	line      int // Lines read so far
+}
+
+func newRecordReader(r io.Reader, decode func(string) string) *recordReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	return &recordReader{scanner: scanner, decode: decode, firstLine: true}
+}
+
+// next returns the next header or record, or io.EOF when the input is exhausted
+func (rr *recordReader) next() (*record, error) {
+	if rr.pending != nil {
+		rec := rr.pending
+		rr.pending = nil
+		return rec, nil
+	}
+
+	var rec *record
+	for rr.scanner.Scan() {
+		line := rr.scanner.Text()
+		rr.line++
+		if rr.firstLine {
+			line = strings.TrimPrefix(line, utf8BOM)
+			rr.firstLine = false
+		}
+		line = strings.TrimRight(rr.decode(line), " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(strings.TrimSpace(line), "!") {
+			header := &record{Header: strings.TrimSpace(line), Line: rr.line}
+			if rec != nil {
+				rr.pending = header
+				return rec, nil
+			}
+			return header, nil
+		}
+
+		if line[0] == '^' {
+			if rec == nil {
+				// Stray terminator, e.g. the doubled ^ some exports write
+				continue
+			}
+			rec.Terminated = true
+			return rec, nil
+		}
+
+		if rec == nil {
+			rec = &record{Line: rr.line}
+		}
+		rec.Fields = append(rec.Fields, field{Code: line[0], Value: strings.TrimSpace(line[1:]), Line: rr.line, Raw: line})
+	}
+	if err := rr.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if rec != nil {
+		return rec, nil
+	}
+	return nil, io.EOF
+}
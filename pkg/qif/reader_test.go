@@ -0,0 +1,53 @@
+package qif
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRecordReader(t *testing.T) {
+	input := "!Type:Bank\r\nD1/5'23\r\nT10.00\r\n^\r\n^\r\n\r\nD1/6'23\r\nT-5.00\r\n!Type:Cat\r\nNFood\r\n"
+	decode, _ := decoderFor(EncodingAuto)
+	rr := newRecordReader(strings.NewReader(input), decode)
+
+	var got []*record
+	for {
+		rec, err := rr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next() error = %v", err)
+		}
+		got = append(got, rec)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("Expected 5 headers and records, got %d", len(got))
+	}
+	if got[0].Header != "!Type:Bank" {
+		t.Errorf("Header = %q, want !Type:Bank", got[0].Header)
+	}
+	if got[1].Get('D') != "1/5'23" || got[1].Get('T') != "10.00" {
+		t.Errorf("Unexpected first record %+v", got[1])
+	}
+	// A record left open is closed by the next header
+	if got[2].Get('T') != "-5.00" || got[3].Header != "!Type:Cat" {
+		t.Errorf("Unterminated record not closed by header: %+v %+v", got[2], got[3])
+	}
+	// A record left open at the end of input is still returned
+	if got[4].Get('N') != "Food" {
+		t.Errorf("Unexpected last record %+v", got[4])
+	}
+	// Line numbers count blank lines and stray terminators
+	if got[1].Line != 2 || !got[1].Terminated {
+		t.Errorf("First record Line = %d, Terminated = %v; want 2, true", got[1].Line, got[1].Terminated)
+	}
+	if got[2].Line != 7 || got[2].Terminated || got[3].Line != 9 || got[4].Terminated {
+		t.Errorf("Unexpected lines or terminators: %+v %+v %+v", got[2], got[3], got[4])
+	}
+	if got[1].Has('P') {
+		t.Error("Has('P') should be false for a record without a payee")
+	}
+}
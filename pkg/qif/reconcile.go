@@ -0,0 +1,93 @@
+package qif
+
+import (
+	"time"
+
+	"qifutil/pkg/money"
+)
+
+// Reconciliation compares the sum of an account's transactions with the
+// balance its !Account record states
+type Reconciliation struct {
+	Account        string
+	OpeningBalance money.Amount // Amount of the Opening Balance transaction
+	OpeningDate    time.Time    // Zero if the register has no Opening Balance transaction
+	Transactions   int          // Transactions counted in Computed
+	Computed       money.Amount // Sum of the transactions up to the stated balance date
+	Stated         money.Amount
+	HasStated      bool      // Whether the file states a balance for the account
+	StatedDate     time.Time // Zero if the balance has no date
+	Unreadable     int       // Transactions left out of Computed because their amount can't be read
+}
+
+// Difference returns the stated balance minus the computed one
+func (r Reconciliation) Difference() money.Amount {
+	return r.Stated - r.Computed
+}
+
+// Balanced reports whether the transactions add up to the stated balance.
+// It is false when the file states no balance.
+func (r Reconciliation) Balanced() bool {
+	return r.HasStated && r.Difference() == 0 && r.Unreadable == 0
+}
+
+// Reconciler sums each account's transactions as they are read, to compare
+// them with the account's stated balance once the file has been read
+type Reconciler struct {
+	accounts []*Account
+	sums     map[*Account]*Reconciliation
+}
+
+// NewReconciler returns an empty Reconciler
+func NewReconciler() *Reconciler {
+	return &Reconciler{sums: make(map[*Account]*Reconciliation)}
+}
+
+// Add counts a transaction of the given account. The stated balance is
+// read when Results is called, so it may be learned later, but transactions
+// after the balance date are only left out if the date is already known.
+func (rc *Reconciler) Add(account *Account, t *Transaction) {
+	sum := rc.sums[account]
+	if sum == nil {
+		sum = &Reconciliation{Account: account.Name}
+		rc.sums[account] = sum
+		rc.accounts = append(rc.accounts, account)
+	}
+
+	if !account.BalanceDate.IsZero() && t.Date.After(account.BalanceDate) {
+		return
+	}
+	amount, err := money.Parse(t.Amount)
+	if err != nil {
+		sum.Unreadable++
+		return
+	}
+	sum.Transactions++
+	sum.Computed += amount
+
+	// Quicken's opening balance is a transfer to the account itself
+	if sum.OpeningDate.IsZero() {
+		if counterpart, ok := TransferAccount(t.Category); (ok && counterpart == account.Name) || t.Payee == "Opening Balance" {
+			sum.OpeningBalance = amount
+			sum.OpeningDate = t.Date
+		}
+	}
+}
+
+// Results returns the reconciliation of every account with transactions,
+// in the order the accounts were first seen
+func (rc *Reconciler) Results() []Reconciliation {
+	results := make([]Reconciliation, 0, len(rc.accounts))
+	for _, account := range rc.accounts {
+		result := *rc.sums[account]
+		if account.Balance != "" {
+			if stated, err := money.Parse(account.Balance); err == nil {
+				result.Stated = stated
+				result.HasStated = true
+				result.StatedDate = account.BalanceDate
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
@@ -0,0 +1,103 @@
+package qif
+
+import (
+	"strings"
+	"testing"
+)
+
+const reconcileQIF = `!Option:AutoSwitch
+!Account
+NChecking
+TBank
+$1,454.77
+/3/31'23
+^
+NSavings
+TBank
+$600.00
+^
+!Clear:AutoSwitch
+!Account
+NChecking
+TBank
+^
+!Type:Bank
+D1/1'23
+T1,000.00
+POpening Balance
+L[Checking]
+^
+D1/8'23
+T-45.23
+PWhole Foods Market
+^
+D2/5'23
+T500.00
+PPaycheck
+^
+D4/2'23
+T-20.00
+PAfter the statement
+^
+!Account
+NSavings
+TBank
+^
+!Type:Bank
+D1/1'23
+T100.00
+POpening Balance
+L[Savings]
+^
+D1/5'23
+T500.00
+PTransfer
+^
+D2/5'23
+T-25.00
+PFee
+^
+!Account
+NVisa
+TCCard
+^
+!Type:CCard
+D1/5'23
+T-120.00
+PAmazon
+^
+`
+
+func TestReconcile(t *testing.T) {
+	file, err := Parse(strings.NewReader(reconcileQIF), Options{})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	checking := file.Account("Checking")
+	if checking.Balance != "1,454.77" || checking.BalanceDate.Format("2006-01-02") != "2023-03-31" {
+		t.Errorf("Stated balance not read: %q %v", checking.Balance, checking.BalanceDate)
+	}
+
+	rc := NewReconciler()
+	for _, account := range file.Accounts {
+		for i := range account.Transactions {
+			rc.Add(account, &account.Transactions[i])
+		}
+	}
+	results := rc.Results()
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+
+	// The transaction after the statement date is left out
+	if r := results[0]; !r.Balanced() || r.Transactions != 3 || r.OpeningBalance != 100000 {
+		t.Errorf("Expected Checking to balance, got %+v", r)
+	}
+	if r := results[1]; r.Balanced() || r.Computed != 57500 || r.Difference() != 2500 {
+		t.Errorf("Expected Savings to be 25.00 off, got %+v (difference %s)", r, r.Difference())
+	}
+	if r := results[2]; r.HasStated || r.Balanced() || r.Computed != -12000 {
+		t.Errorf("Expected Visa to have no stated balance, got %+v", r)
+	}
+}
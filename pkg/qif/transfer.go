@@ -0,0 +1,101 @@
+package qif
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"qifutil/pkg/money"
+)
+
+// TransferAccount returns the account named by a transfer category such as
+// [Savings] or [Savings]/Vacation, and false for ordinary categories
+func TransferAccount(category string) (string, bool) {
+	category = strings.TrimSpace(category)
+	if !strings.HasPrefix(category, "[") {
+		return "", false
+	}
+	end := strings.Index(category, "]")
+	if end < 0 {
+		return "", false
+	}
+	return strings.TrimSpace(category[1:end]), true
+}
+
+// TransferSide is one account's half of a transfer
+type TransferSide struct {
+	Account     string // Account whose register holds the entry
+	Counterpart string // Account named in the bracketed category
+	Index       int    // 1-based position of the transaction in its account's register
+	Date        time.Time
+	Amount      money.Amount
+}
+
+// transferKey identifies the half that a TransferSide is waiting for
+type transferKey struct {
+	account     string
+	counterpart string
+	date        time.Time
+	amount      money.Amount
+}
+
+// sideRef locates a transaction by account and register position
+type sideRef struct {
+	account string
+	index   int
+}
+
+// TransferMatcher pairs the two halves of transfers between accounts. A half
+// matches the earliest unpaired half in the counterpart account that names
+// this account, falls on the same date and has the opposite amount.
+type TransferMatcher struct {
+	pending map[transferKey][]TransferSide // Unpaired halves, by the half they match
+	pairs   map[sideRef]int                // Pair number of every matched half
+	count   int
+}
+
+// NewTransferMatcher returns an empty TransferMatcher
+func NewTransferMatcher() *TransferMatcher {
+	return &TransferMatcher{
+		pending: make(map[transferKey][]TransferSide),
+		pairs:   make(map[sideRef]int),
+	}
+}
+
+// Add records a transfer half, pairing it with a waiting counterpart if there is one
+func (m *TransferMatcher) Add(side TransferSide) {
+	own := transferKey{account: side.Account, counterpart: side.Counterpart, date: side.Date, amount: side.Amount}
+	if waiting := m.pending[own]; len(waiting) > 0 {
+		other := waiting[0]
+		m.pending[own] = waiting[1:]
+		m.count++
+		m.pairs[sideRef{other.Account, other.Index}] = m.count
+		m.pairs[sideRef{side.Account, side.Index}] = m.count
+		return
+	}
+
+	wanted := transferKey{account: side.Counterpart, counterpart: side.Account, date: side.Date, amount: -side.Amount}
+	m.pending[wanted] = append(m.pending[wanted], side)
+}
+
+// Pair returns the pair number shared by both halves of the transfer at
+// the given register position, or false if it has no counterpart
+func (m *TransferMatcher) Pair(account string, index int) (int, bool) {
+	pair, ok := m.pairs[sideRef{account, index}]
+	return pair, ok
+}
+
+// Unmatched returns every half that has no counterpart
+func (m *TransferMatcher) Unmatched() []TransferSide {
+	var sides []TransferSide
+	for _, waiting := range m.pending {
+		sides = append(sides, waiting...)
+	}
+	sort.Slice(sides, func(i, j int) bool {
+		if sides[i].Account != sides[j].Account {
+			return sides[i].Account < sides[j].Account
+		}
+		return sides[i].Index < sides[j].Index
+	})
+	return sides
+}
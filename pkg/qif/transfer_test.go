@@ -0,0 +1,54 @@
+package qif
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransferAccount(t *testing.T) {
+	tests := []struct {
+		category string
+		want     string
+		ok       bool
+	}{
+		{"[Savings]", "Savings", true},
+		{"[Joint Checking]/Vacation", "Joint Checking", true},
+		{" [Visa] ", "Visa", true},
+		{"Food:Groceries", "", false},
+		{"", "", false},
+		{"[Unclosed", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := TransferAccount(tt.category)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("TransferAccount(%q) = %q, %v; want %q, %v", tt.category, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTransferMatcher(t *testing.T) {
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	m := NewTransferMatcher()
+
+	m.Add(TransferSide{Account: "Checking", Counterpart: "Savings", Index: 1, Date: day, Amount: -50000})
+	m.Add(TransferSide{Account: "Checking", Counterpart: "Savings", Index: 2, Date: day, Amount: -2500})
+	// Same amount but the wrong date, so it doesn't pair
+	m.Add(TransferSide{Account: "Savings", Counterpart: "Checking", Index: 1, Date: day.AddDate(0, 0, 1), Amount: 2500})
+	m.Add(TransferSide{Account: "Savings", Counterpart: "Checking", Index: 2, Date: day, Amount: 50000})
+
+	pair, ok := m.Pair("Checking", 1)
+	if !ok {
+		t.Fatal("Expected Checking #1 to be paired")
+	}
+	if other, _ := m.Pair("Savings", 2); other != pair {
+		t.Errorf("Savings #2 pair = %d, want %d", other, pair)
+	}
+
+	unmatched := m.Unmatched()
+	if len(unmatched) != 2 {
+		t.Fatalf("Expected 2 unmatched halves, got %+v", unmatched)
+	}
+	if unmatched[0].Account != "Checking" || unmatched[0].Index != 2 || unmatched[1].Account != "Savings" || unmatched[1].Index != 1 {
+		t.Errorf("Unexpected unmatched halves %+v", unmatched)
+	}
+}
@@ -0,0 +1,162 @@
+package qif
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Writer writes QIF data that Quicken, and Reader, can read back. Each
+// account's register is preceded by its !Account record, so a file holding
+// several accounts imports into the right ones.
+type Writer struct {
+	w    *bufio.Writer
+	opts DateOptions
+	err  error // First write error; later writes are skipped
+}
+
+// NewWriter returns a Writer that writes QIF data to w. Dates are written
+// day first when opts.DayFirst is set.
+func NewWriter(w io.Writer, opts DateOptions) *Writer {
+	return &Writer{w: bufio.NewWriter(w), opts: opts}
+}
+
+// FormatDate formats a date for a D line. Years are written in full so the
+// date reads back the same whatever the reader's pivot year.
+func FormatDate(date time.Time, opts DateOptions) string {
+	if opts.DayFirst {
+		return fmt.Sprintf("%d/%d/%04d", date.Day(), int(date.Month()), date.Year())
+	}
+	return fmt.Sprintf("%d/%d/%04d", int(date.Month()), date.Day(), date.Year())
+}
+
+// WriteAccount writes the account's !Account record, with its stated
+// balance if it has one, followed by the !Type header of its register
+func (w *Writer) WriteAccount(account *Account) error {
+	accountType := account.Type
+	if accountType == "" {
+		accountType = "Bank"
+	}
+	w.line("!Account")
+	w.field('N', account.Name)
+	w.field('T', accountType)
+	w.field('$', account.Balance)
+	if account.Balance != "" && !account.BalanceDate.IsZero() {
+		w.field('/', FormatDate(account.BalanceDate, w.opts))
+	}
+	w.end()
+	w.line("!Type:" + accountType)
+	return w.err
+}
+
+// WriteTransaction writes a register entry, with its split lines
+func (w *Writer) WriteTransaction(t *Transaction) error {
+	w.field('D', FormatDate(t.Date, w.opts))
+	w.field('U', t.Amount)
+	w.field('T', t.Amount)
+	w.field('C', t.Cleared)
+	w.field('N', t.Number)
+	w.field('P', t.Payee)
+	for _, line := range t.Address {
+		w.line("A" + clean(line))
+	}
+	w.field('M', t.Memo)
+	w.field('L', t.Category)
+	for _, split := range t.Splits {
+		// S starts every split, even one without a category
+		w.line("S" + clean(split.Category))
+		w.field('E', split.Memo)
+		w.field('%', split.Percent)
+		w.field('$', split.Amount)
+	}
+	w.end()
+	return w.err
+}
+
+// WriteCategories writes a !Type:Cat list. Nothing is written for an empty list.
+func (w *Writer) WriteCategories(categories []Category) error {
+	if len(categories) == 0 {
+		return w.err
+	}
+	w.line("!Type:Cat")
+	for _, c := range categories {
+		w.field('N', c.Name)
+		w.field('D', c.Description)
+		if c.TaxRelated {
+			w.line("T")
+		}
+		if c.Income {
+			w.line("I")
+		} else {
+			w.line("E")
+		}
+		w.end()
+	}
+	return w.err
+}
+
+// WriteClasses writes a !Type:Class list. Nothing is written for an empty list.
+func (w *Writer) WriteClasses(classes []Class) error {
+	if len(classes) == 0 {
+		return w.err
+	}
+	w.line("!Type:Class")
+	for _, c := range classes {
+		w.field('N', c.Name)
+		w.field('D', c.Description)
+		w.end()
+	}
+	return w.err
+}
+
+// WriteTags writes a !Type:Tag list. Nothing is written for an empty list.
+func (w *Writer) WriteTags(tags []Tag) error {
+	if len(tags) == 0 {
+		return w.err
+	}
+	w.line("!Type:Tag")
+	for _, t := range tags {
+		w.field('N', t.Name)
+		w.field('D', t.Description)
+		w.end()
+	}
+	return w.err
+}
+
+// Flush writes any buffered data and returns the first error met while writing
+func (w *Writer) Flush() error {
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+	return w.err
+}
+
+// field writes a field line, leaving out empty values
+func (w *Writer) field(code byte, value string) {
+	if value = clean(value); value != "" {
+		w.line(string(code) + value)
+	}
+}
+
+// end writes the ^ that terminates a record
+func (w *Writer) end() {
+	w.line("^")
+}
+
+// line writes one line of output
+func (w *Writer) line(s string) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.WriteString(s + "\n")
+}
+
+// lineBreaks turns the line breaks inside a value into spaces
+var lineBreaks = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+
+// clean puts a value on one line, as every QIF field must be
+func clean(value string) string {
+	return strings.TrimSpace(lineBreaks.Replace(value))
+}
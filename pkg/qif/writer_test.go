@@ -0,0 +1,104 @@
+package qif
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripQIF adds a stated balance, splits and an address to sampleQIF
+const roundTripQIF = sampleQIF + `!Account
+NSavings
+TBank
+$1,250.00
+/3/31'23
+^
+!Type:Bank
+D3/1'23
+T-250.00
+N1043
+PGrocery Mart
+A12 High Street
+ASpringfield
+MWeekly shop
+L--Split--
+SFood:Groceries
+EFood
+$-200.00
+SHousehold/Vacation
+$-50.00
+^
+D3/2'23
+T1,500.00
+L[Checking Account]
+^
+`
+
+func TestWriteRoundTrip(t *testing.T) {
+	for _, opts := range []DateOptions{{}, {DayFirst: true}} {
+		want, err := Parse(strings.NewReader(roundTripQIF), Options{})
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		// Securities belong to investment registers, which aren't written
+		want.Securities = nil
+
+		var buf bytes.Buffer
+		w := NewWriter(&buf, opts)
+		w.WriteCategories(want.Categories)
+		w.WriteClasses(want.Classes)
+		w.WriteTags(want.Tags)
+		for _, account := range want.Accounts {
+			w.WriteAccount(account)
+			for i := range account.Transactions {
+				w.WriteTransaction(&account.Transactions[i])
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("Flush() error = %v", err)
+		}
+
+		got, err := Parse(&buf, Options{Date: opts})
+		if err != nil {
+			t.Fatalf("Parse() of written QIF error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Round trip with %+v changed the file\ngot:  %+v\nwant: %+v", opts, got, want)
+		}
+	}
+}
+
+func TestWriteTransaction(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, DateOptions{})
+	w.WriteAccount(&Account{Name: "Checking", Type: "Bank"})
+	w.WriteTransaction(&Transaction{
+		Date:     time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC),
+		Amount:   "-45.23",
+		Payee:    "Corner Store",
+		Memo:     "Two\nlines",
+		Category: "Food:Groceries/Vacation",
+		Splits:   []Split{{Amount: "-45.23"}},
+	})
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := "!Account\nNChecking\nTBank\n^\n!Type:Bank\n" +
+		"D1/5/2023\nU-45.23\nT-45.23\nPCorner Store\nMTwo lines\nLFood:Groceries/Vacation\nS\n$-45.23\n^\n"
+	if buf.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2002, 3, 9, 0, 0, 0, 0, time.UTC)
+	if got := FormatDate(date, DateOptions{}); got != "3/9/2002" {
+		t.Errorf("FormatDate() = %q, want 3/9/2002", got)
+	}
+	if got := FormatDate(date, DateOptions{DayFirst: true}); got != "9/3/2002" {
+		t.Errorf("FormatDate() day first = %q, want 9/3/2002", got)
+	}
+}
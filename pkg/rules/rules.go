@@ -0,0 +1,260 @@
+// Package rules evaluates a rules file of conditions and actions against
+// transactions, for categorization that plain mapping files can't express.
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"qifutil/pkg/money"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Set is the contents of a rules file
+type Set struct {
+	Rules []*Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule applies its actions to every transaction that meets all of its conditions
+type Rule struct {
+	Name string     `json:"name" yaml:"name"`
+	When Conditions `json:"when" yaml:"when"`
+	Then Actions    `json:"then" yaml:"then"`
+	Stop bool       `json:"stop" yaml:"stop"` // Skip the remaining rules after this one matches
+}
+
+// Conditions are tested against the transaction as read from the QIF file,
+// before any mapping. Conditions that are not set always pass.
+type Conditions struct {
+	Payee    *TextMatch   `json:"payee" yaml:"payee"`
+	Memo     *TextMatch   `json:"memo" yaml:"memo"`
+	Account  *TextMatch   `json:"account" yaml:"account"`
+	Category *TextMatch   `json:"category" yaml:"category"`
+	Amount   *AmountRange `json:"amount" yaml:"amount"`
+	Date     *DateRange   `json:"date" yaml:"date"`
+}
+
+// TextMatch tests a text field. Equals and Contains ignore case; Matches
+// is a regular expression.
+type TextMatch struct {
+	Equals   string `json:"equals" yaml:"equals"`
+	Contains string `json:"contains" yaml:"contains"`
+	Matches  string `json:"matches" yaml:"matches"`
+
+	pattern *regexp.Regexp
+}
+
+// AmountRange tests the signed amount; both bounds are inclusive
+type AmountRange struct {
+	Min *Amount `json:"min" yaml:"min"`
+	Max *Amount `json:"max" yaml:"max"`
+}
+
+// DateRange tests the transaction date; both bounds are inclusive YYYY-MM-DD dates
+type DateRange struct {
+	From string `json:"from" yaml:"from"`
+	To   string `json:"to" yaml:"to"`
+
+	from, to time.Time
+}
+
+// Actions change a matching transaction
+type Actions struct {
+	Category   string   `json:"category" yaml:"category"`
+	Payee      string   `json:"payee" yaml:"payee"`
+	AddTags    []string `json:"addTags" yaml:"addTags"`
+	AppendNote string   `json:"appendNote" yaml:"appendNote"`
+	Drop       bool     `json:"drop" yaml:"drop"`
+}
+
+// Amount is a rule amount written as a number or a string, e.g. -20 or "-20.00"
+type Amount struct {
+	money.Amount
+}
+
+// UnmarshalJSON reads an amount from a JSON number or string
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	value, err := money.Parse(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	a.Amount = value
+	return nil
+}
+
+// UnmarshalYAML reads an amount from a YAML scalar
+func (a *Amount) UnmarshalYAML(node *yaml.Node) error {
+	value, err := money.Parse(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	a.Amount = value
+	return nil
+}
+
+// Transaction holds the fields that conditions are tested against
+type Transaction struct {
+	Date     time.Time
+	Payee    string
+	Memo     string
+	Account  string
+	Category string
+	Amount   money.Amount
+}
+
+// Result is the combined effect of every rule that matched a transaction
+type Result struct {
+	Category    string // Category to use, if SetCategory
+	SetCategory bool
+	Payee       string // Payee to use, if SetPayee
+	SetPayee    bool
+	Tags        []string // Tags to add
+	Notes       []string // Notes to append
+	Drop        bool
+	Matched     []string // Names of the rules that matched, in order
+}
+
+// Load reads a rules file. Files ending in .json are read as JSON and
+// anything else as YAML. Unknown keys are rejected so typos don't silently
+// disable a rule.
+func Load(path string) (*Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &Set{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(set)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(set)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file %s: %w", path, err)
+	}
+
+	if err := set.compile(); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// compile checks every rule and prepares its patterns and dates
+func (s *Set) compile() error {
+	for i, rule := range s.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		for _, match := range []*TextMatch{rule.When.Payee, rule.When.Memo, rule.When.Account, rule.When.Category} {
+			if match == nil || match.Matches == "" {
+				continue
+			}
+			pattern, err := regexp.Compile(match.Matches)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern %q: %w", rule.Name, match.Matches, err)
+			}
+			match.pattern = pattern
+		}
+		if dates := rule.When.Date; dates != nil {
+			var err error
+			if dates.From != "" {
+				if dates.from, err = time.Parse("2006-01-02", dates.From); err != nil {
+					return fmt.Errorf("%s: invalid from date %q, use YYYY-MM-DD", rule.Name, dates.From)
+				}
+			}
+			if dates.To != "" {
+				if dates.to, err = time.Parse("2006-01-02", dates.To); err != nil {
+					return fmt.Errorf("%s: invalid to date %q, use YYYY-MM-DD", rule.Name, dates.To)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Apply evaluates the rules in order and returns the combined actions of
+// those that match. When several rules set the same field the last one wins.
+func (s *Set) Apply(t Transaction) Result {
+	var result Result
+	if s == nil {
+		return result
+	}
+
+	for _, rule := range s.Rules {
+		if !rule.When.match(t) {
+			continue
+		}
+		result.Matched = append(result.Matched, rule.Name)
+
+		if rule.Then.Category != "" {
+			result.Category, result.SetCategory = rule.Then.Category, true
+		}
+		if rule.Then.Payee != "" {
+			result.Payee, result.SetPayee = rule.Then.Payee, true
+		}
+		result.Tags = append(result.Tags, rule.Then.AddTags...)
+		if rule.Then.AppendNote != "" {
+			result.Notes = append(result.Notes, rule.Then.AppendNote)
+		}
+		if rule.Then.Drop {
+			result.Drop = true
+		}
+
+		if rule.Stop {
+			break
+		}
+	}
+	return result
+}
+
+// match reports whether t meets every condition that is set
+func (c *Conditions) match(t Transaction) bool {
+	if !c.Payee.match(t.Payee) || !c.Memo.match(t.Memo) || !c.Account.match(t.Account) || !c.Category.match(t.Category) {
+		return false
+	}
+	if c.Amount != nil {
+		if c.Amount.Min != nil && t.Amount < c.Amount.Min.Amount {
+			return false
+		}
+		if c.Amount.Max != nil && t.Amount > c.Amount.Max.Amount {
+			return false
+		}
+	}
+	if c.Date != nil {
+		if !c.Date.from.IsZero() && t.Date.Before(c.Date.from) {
+			return false
+		}
+		if !c.Date.to.IsZero() && t.Date.After(c.Date.to) {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether value passes every test that is set
+func (m *TextMatch) match(value string) bool {
+	if m == nil {
+		return true
+	}
+	if m.Equals != "" && !strings.EqualFold(value, m.Equals) {
+		return false
+	}
+	if m.Contains != "" && !strings.Contains(strings.ToLower(value), strings.ToLower(m.Contains)) {
+		return false
+	}
+	if m.pattern != nil && !m.pattern.MatchString(value) {
+		return false
+	}
+	return true
+}
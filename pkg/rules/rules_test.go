@@ -0,0 +1,108 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleYAML = `rules:
+  - name: Fuel
+    when:
+      payee: {contains: shell}
+      amount: {max: -20}
+    then:
+      category: Auto:Fuel
+      addTags: [Car]
+  - name: Business travel
+    when:
+      category: {matches: "^Travel:"}
+      date: {from: 2023-02-01, to: 2023-02-28}
+    then:
+      addTags: [Business]
+      appendNote: "(reimbursable)"
+  - name: Transfers out
+    when:
+      account: {equals: checking account}
+      category: {equals: "[Savings]"}
+    then:
+      drop: true
+`
+
+func writeRules(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApply(t *testing.T) {
+	set, err := Load(writeRules(t, "rules.yaml", sampleYAML))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	feb := time.Date(2023, 2, 7, 0, 0, 0, 0, time.UTC)
+
+	fuel := set.Apply(Transaction{Date: feb, Payee: "SHELL OIL 1234", Amount: -2850})
+	if !fuel.SetCategory || fuel.Category != "Auto:Fuel" || len(fuel.Tags) != 1 || fuel.Tags[0] != "Car" {
+		t.Errorf("Unexpected fuel result %+v", fuel)
+	}
+
+	// -15.00 is above the -20 maximum
+	small := set.Apply(Transaction{Date: feb, Payee: "Shell", Amount: -1500})
+	if small.SetCategory || len(small.Matched) != 0 {
+		t.Errorf("Small purchase should not match, got %+v", small)
+	}
+
+	hotel := set.Apply(Transaction{Date: feb, Payee: "Mariott", Category: "Travel:Hotels", Amount: -25000})
+	if hotel.SetCategory || len(hotel.Notes) != 1 || hotel.Notes[0] != "(reimbursable)" || hotel.Tags[0] != "Business" {
+		t.Errorf("Unexpected hotel result %+v", hotel)
+	}
+	march := set.Apply(Transaction{Date: feb.AddDate(0, 1, 0), Category: "Travel:Hotels"})
+	if len(march.Matched) != 0 {
+		t.Errorf("March should be outside the date range, got %+v", march)
+	}
+
+	transfer := set.Apply(Transaction{Account: "Checking Account", Category: "[Savings]"})
+	if !transfer.Drop || transfer.Matched[0] != "Transfers out" {
+		t.Errorf("Unexpected transfer result %+v", transfer)
+	}
+}
+
+func TestApplyStopAndOverride(t *testing.T) {
+	set, err := Load(writeRules(t, "rules.json", `{"rules": [
+		{"when": {"payee": {"contains": "amazon"}}, "then": {"category": "Shopping"}},
+		{"when": {"payee": {"contains": "prime video"}}, "then": {"category": "Entertainment", "payee": "Prime Video"}, "stop": true},
+		{"when": {"payee": {"contains": "amazon"}}, "then": {"category": "Never"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	got := set.Apply(Transaction{Payee: "AMAZON PRIME VIDEO", Amount: -899})
+	if got.Category != "Entertainment" || got.Payee != "Prime Video" {
+		t.Errorf("Unexpected result %+v", got)
+	}
+	if strings.Join(got.Matched, ",") != "rule 1,rule 2" {
+		t.Errorf("Matched = %v, want rule 1 and rule 2", got.Matched)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":   "rules:\n  - when:\n      payeee: {contains: x}\n",
+		"bad pattern":   "rules:\n  - when:\n      payee: {matches: \"([\"}\n",
+		"bad amount":    "rules:\n  - when:\n      amount: {min: lots}\n",
+		"bad from date": "rules:\n  - when:\n      date: {from: 1/5/2023}\n",
+	}
+	for name, content := range tests {
+		if _, err := Load(writeRules(t, "rules.yaml", content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 )
 
@@ -20,6 +22,16 @@ type ValidationTracker struct {
 
 	// Duplicates (same date, payee, amount)
 	DuplicateTransactions []DuplicateWarning
+	RemovedDuplicates     int // Exact copies left out of the output
+
+	// Transfers whose counterpart was not found in the other account
+	UnmatchedTransfers []TransferWarning
+
+	// Accounts whose transactions don't add up to their stated balance
+	BalanceMismatches []BalanceWarning
+
+	// Records the QIF parser skipped or could not fully interpret
+	ParseProblems []ParseProblem
 
 	// Mapping issues
 	UnusedMappings map[string][]string // mapping type -> list of unused values
@@ -41,10 +53,38 @@ type TransactionIssue struct {
 
 // DuplicateWarning represents a potential duplicate transaction
 type DuplicateWarning struct {
-	Date   string
-	Payee  string
-	Amount string
-	Count  int // How many duplicates found
+	Account string // Account(s) holding the transactions, if known
+	Date    string
+	Payee   string
+	Amount  string
+	Count   int // How many duplicates found
+	Exact   int // How many are identical to an earlier transaction
+}
+
+// TransferWarning represents a transfer with no matching entry in the other account
+type TransferWarning struct {
+	Date        string
+	Account     string // Account holding the transfer
+	Counterpart string // Account the transfer names
+	Amount      string
+}
+
+// BalanceWarning represents an account whose transactions don't add up to the balance the file states
+type BalanceWarning struct {
+	Account    string
+	Stated     string // Balance from the !Account record
+	Computed   string // Sum of the parsed transactions
+	Difference string // Stated minus computed
+	AsOf       string // Date of the stated balance, if given
+}
+
+// ParseProblem represents a QIF record the parser had trouble with
+type ParseProblem struct {
+	Line     int    // Line number where the record starts
+	Severity string // info, warning or error
+	Account  string // Register holding the record, if known
+	Message  string
+	Text     string // The record as written in the file
 }
 
 // NewValidationTracker creates a new validation tracker
@@ -125,18 +165,71 @@ func (vt *ValidationTracker) RecordUnusedMapping(mappingType string, values []st
 
 // AddDuplicate records a potential duplicate
 func (vt *ValidationTracker) AddDuplicate(date, payee, amount string, count int) {
+	vt.AddAccountDuplicate("", date, payee, amount, count, 0)
+}
+
+// AddAccountDuplicate records a potential duplicate in an account, with the
+// number of transactions in the group that are exact copies
+func (vt *ValidationTracker) AddAccountDuplicate(account, date, payee, amount string, count, exact int) {
 	vt.mu.Lock()
 	defer vt.mu.Unlock()
 	if count > 1 {
 		vt.DuplicateTransactions = append(vt.DuplicateTransactions, DuplicateWarning{
-			Date:   date,
-			Payee:  payee,
-			Amount: amount,
-			Count:  count,
+			Account: account,
+			Date:    date,
+			Payee:   payee,
+			Amount:  amount,
+			Count:   count,
+			Exact:   exact,
 		})
 	}
 }
 
+// AddRemovedDuplicate records an exact duplicate that was left out of the output
+func (vt *ValidationTracker) AddRemovedDuplicate() {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	vt.RemovedDuplicates++
+}
+
+// AddUnmatchedTransfer records a transfer whose counterpart was not found
+func (vt *ValidationTracker) AddUnmatchedTransfer(date, account, counterpart, amount string) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	vt.UnmatchedTransfers = append(vt.UnmatchedTransfers, TransferWarning{
+		Date:        date,
+		Account:     account,
+		Counterpart: counterpart,
+		Amount:      amount,
+	})
+}
+
+// AddBalanceMismatch records an account that doesn't reconcile with its stated balance
+func (vt *ValidationTracker) AddBalanceMismatch(account, stated, computed, difference, asOf string) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	vt.BalanceMismatches = append(vt.BalanceMismatches, BalanceWarning{
+		Account:    account,
+		Stated:     stated,
+		Computed:   computed,
+		Difference: difference,
+		AsOf:       asOf,
+	})
+}
+
+// AddParseProblem records a record the parser skipped or had trouble with
+func (vt *ValidationTracker) AddParseProblem(line int, severity, account, message, text string) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	vt.ParseProblems = append(vt.ParseProblems, ParseProblem{
+		Line:     line,
+		Severity: severity,
+		Account:  account,
+		Message:  message,
+		Text:     text,
+	})
+}
+
 // hasWarningsUnlocked checks for warnings without acquiring the lock
 // Must only be called when the lock is already held
 func (vt *ValidationTracker) hasWarningsUnlocked() bool {
@@ -144,6 +237,9 @@ func (vt *ValidationTracker) hasWarningsUnlocked() bool {
 		vt.MissingCategory > 0 ||
 		vt.ZeroAmounts > 0 ||
 		len(vt.DuplicateTransactions) > 0 ||
+		len(vt.UnmatchedTransfers) > 0 ||
+		len(vt.ParseProblems) > 0 ||
+		len(vt.BalanceMismatches) > 0 ||
 		len(vt.UnusedMappings) > 0 ||
 		len(vt.UnmatchedData) > 0
 }
@@ -155,15 +251,20 @@ func (vt *ValidationTracker) HasWarnings() bool {
 	return vt.hasWarningsUnlocked()
 }
 
-// WriteValidationLog writes a detailed validation log to a file
+// WriteValidationLog writes a detailed validation log to validation.log
 func (vt *ValidationTracker) WriteValidationLog(outputPath string) error {
+	return vt.WriteValidationLogWithName(outputPath, "validation.log")
+}
+
+// WriteValidationLogWithName writes a detailed validation log to a file with a specific name
+func (vt *ValidationTracker) WriteValidationLogWithName(outputPath string, filename string) error {
 	vt.mu.Lock()
 	defer vt.mu.Unlock()
 
-	logPath := filepath.Join(outputPath, "validation.log")
+	logPath := filepath.Join(outputPath, filename)
 	file, err := os.Create(logPath)
 	if err != nil {
-		return fmt.Errorf("failed to create validation.log: %w", err)
+		return fmt.Errorf("failed to create %s: %w", filename, err)
 	}
 	defer file.Close()
 
@@ -186,6 +287,23 @@ func (vt *ValidationTracker) WriteValidationLog(outputPath string) error {
 	fmt.Fprintf(file, "ISSUES FOUND\n")
 	fmt.Fprintf(file, "------------\n")
 
+	if len(vt.ParseProblems) > 0 {
+		fmt.Fprintf(file, "Unreadable records: %d problems found while reading the QIF file\n", len(vt.ParseProblems))
+		for _, p := range vt.ParseProblems {
+			fmt.Fprintf(file, "  - Line %d [%s]: %s", p.Line, p.Severity, p.Message)
+			if p.Account != "" {
+				fmt.Fprintf(file, " (Account: %s)", p.Account)
+			}
+			fmt.Fprintf(file, "\n")
+			for _, line := range strings.Split(p.Text, "\n") {
+				if line != "" {
+					fmt.Fprintf(file, "      | %s\n", line)
+				}
+			}
+		}
+		fmt.Fprintf(file, "\n")
+	}
+
 	if vt.MissingPayees > 0 {
 		fmt.Fprintf(file, "Missing payees: %d transactions\n", vt.MissingPayees)
 	}
@@ -211,97 +329,46 @@ func (vt *ValidationTracker) WriteValidationLog(outputPath string) error {
 
 	if len(vt.DuplicateTransactions) > 0 {
 		fmt.Fprintf(file, "\nPotential duplicates: %d groups detected\n", len(vt.DuplicateTransactions))
-		for _, dup := range vt.DuplicateTransactions {
-			fmt.Fprintf(file, "  - Date: %s | Payee: %s | Amount: %s (appears %d times)\n",
-				dup.Date, dup.Payee, dup.Amount, dup.Count)
+		if vt.RemovedDuplicates > 0 {
+			fmt.Fprintf(file, "  (Removed exact copies: %d)\n", vt.RemovedDuplicates)
 		}
-	}
-
-	if len(vt.UnusedMappings) > 0 {
-		fmt.Fprintf(file, "\nUnused mapping rules:\n")
-		for mappingType, values := range vt.UnusedMappings {
-			fmt.Fprintf(file, "  %s mapping: %d rules never used\n", mappingType, len(values))
-			for _, val := range values {
-				fmt.Fprintf(file, "    - \"%s\"\n", val)
+		for _, dup := range vt.DuplicateTransactions {
+			fmt.Fprintf(file, "  - ")
+			if dup.Account != "" {
+				fmt.Fprintf(file, "Account: %s | ", dup.Account)
 			}
+			fmt.Fprintf(file, "Date: %s | Payee: %s | Amount: %s (appears %d times", dup.Date, dup.Payee, dup.Amount, dup.Count)
+			if dup.Exact > 0 {
+				fmt.Fprintf(file, ", exact copies: %d", dup.Exact)
+			}
+			fmt.Fprintf(file, ")\n")
 		}
 	}
 
-	if len(vt.UnmatchedData) > 0 {
-		fmt.Fprintf(file, "\nUnmapped values:\n")
-		for value, count := range vt.UnmatchedData {
-			fmt.Fprintf(file, "  - \"%s\": appears %d times\n", value, count)
+	if len(vt.UnmatchedTransfers) > 0 {
+		fmt.Fprintf(file, "\nUnmatched transfers: %d transfers have no counterpart\n", len(vt.UnmatchedTransfers))
+		for _, tr := range vt.UnmatchedTransfers {
+			fmt.Fprintf(file, "  - Date: %s | Account: %s | To: %s | Amount: %s\n",
+				tr.Date, tr.Account, tr.Counterpart, tr.Amount)
 		}
 	}
 
-	return nil
-}
-
-// WriteValidationLogWithName writes a detailed validation log to a file with a specific name
-func (vt *ValidationTracker) WriteValidationLogWithName(outputPath string, filename string) error {
-	vt.mu.Lock()
-	defer vt.mu.Unlock()
-
-	logPath := filepath.Join(outputPath, filename)
-	file, err := os.Create(logPath)
-	if err != nil {
-		return fmt.Errorf("failed to create %s: %w", filename, err)
-	}
-	defer file.Close()
-
-	// Write header
-	fmt.Fprintf(file, "QIFUTIL Data Validation Report\n")
-	fmt.Fprintf(file, "===============================\n\n")
-
-	// Write summary
-	fmt.Fprintf(file, "SUMMARY\n")
-	fmt.Fprintf(file, "-------\n")
-	fmt.Fprintf(file, "Total transactions processed: %d\n", vt.TotalProcessed)
-	fmt.Fprintf(file, "Total transactions in file: %d\n\n", vt.TotalTransactions)
-
-	// Write issues
-	if !vt.hasWarningsUnlocked() {
-		fmt.Fprintf(file, "✓ No validation issues found\n")
-		return nil
-	}
-
-	fmt.Fprintf(file, "ISSUES FOUND\n")
-	fmt.Fprintf(file, "------------\n")
-
-	if vt.MissingPayees > 0 {
-		fmt.Fprintf(file, "Missing payees: %d transactions\n", vt.MissingPayees)
-	}
-
-	if vt.MissingCategory > 0 {
-		fmt.Fprintf(file, "Missing categories: %d transactions\n", vt.MissingCategory)
-	}
-
-	if vt.ZeroAmounts > 0 {
-		fmt.Fprintf(file, "Zero amounts: %d transactions\n", vt.ZeroAmounts)
-		if vt.SkippedZeroAmount > 0 {
-			fmt.Fprintf(file, "  (Skipped: %d)\n", vt.SkippedZeroAmount)
-		}
-		// Show details of zero-amount transactions
-		fmt.Fprintf(file, "\n  Transaction details:\n")
-		for _, issue := range vt.TransactionIssues {
-			if issue.IssueType == "ZeroAmount" {
-				fmt.Fprintf(file, "    Date: %s | Payee: %s | Amount: %s | Category: %s\n",
-					issue.Date, issue.Payee, issue.Amount, issue.Category)
+	if len(vt.BalanceMismatches) > 0 {
+		fmt.Fprintf(file, "\nBalance mismatches: %d accounts don't add up to their stated balance\n", len(vt.BalanceMismatches))
+		for _, b := range vt.BalanceMismatches {
+			asOf := ""
+			if b.AsOf != "" {
+				asOf = " as of " + b.AsOf
 			}
-		}
-	}
-
-	if len(vt.DuplicateTransactions) > 0 {
-		fmt.Fprintf(file, "\nPotential duplicates: %d groups detected\n", len(vt.DuplicateTransactions))
-		for _, dup := range vt.DuplicateTransactions {
-			fmt.Fprintf(file, "  - Date: %s | Payee: %s | Amount: %s (appears %d times)\n",
-				dup.Date, dup.Payee, dup.Amount, dup.Count)
+			fmt.Fprintf(file, "  - Account: %s | Stated: %s%s | Transactions: %s | Difference: %s\n",
+				b.Account, b.Stated, asOf, b.Computed, b.Difference)
 		}
 	}
 
 	if len(vt.UnusedMappings) > 0 {
 		fmt.Fprintf(file, "\nUnused mapping rules:\n")
-		for mappingType, values := range vt.UnusedMappings {
+		for _, mappingType := range sortedKeys(vt.UnusedMappings) {
+			values := vt.UnusedMappings[mappingType]
 			fmt.Fprintf(file, "  %s mapping: %d rules never used\n", mappingType, len(values))
 			for _, val := range values {
 				fmt.Fprintf(file, "    - \"%s\"\n", val)
@@ -311,14 +378,40 @@ func (vt *ValidationTracker) WriteValidationLogWithName(outputPath string, filen
 
 	if len(vt.UnmatchedData) > 0 {
 		fmt.Fprintf(file, "\nUnmapped values:\n")
-		for value, count := range vt.UnmatchedData {
-			fmt.Fprintf(file, "  - \"%s\": appears %d times\n", value, count)
+		for _, value := range vt.unmatchedByCount() {
+			fmt.Fprintf(file, "  - \"%s\": appears %d times\n", value, vt.UnmatchedData[value])
 		}
 	}
 
 	return nil
 }
 
+// sortedKeys returns the keys of a map in sorted order, so reports are stable
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unmatchedByCount returns the unmapped values, most frequent first
+// Must only be called when the lock is already held
+func (vt *ValidationTracker) unmatchedByCount() []string {
+	values := make([]string, 0, len(vt.UnmatchedData))
+	for value := range vt.UnmatchedData {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if vt.UnmatchedData[values[i]] != vt.UnmatchedData[values[j]] {
+			return vt.UnmatchedData[values[i]] > vt.UnmatchedData[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	return values
+}
+
 // PrintSummary prints a validation summary
 func (vt *ValidationTracker) PrintSummary() {
 	vt.mu.Lock()
@@ -332,6 +425,10 @@ func (vt *ValidationTracker) PrintSummary() {
 	fmt.Println("\n⚠️  Data Validation Summary:")
 	fmt.Println("=============================")
 
+	if len(vt.ParseProblems) > 0 {
+		fmt.Printf("  • Unreadable records: %d problems found while reading the QIF file\n", len(vt.ParseProblems))
+	}
+
 	if vt.MissingPayees > 0 {
 		fmt.Printf("  • Missing payees: %d transactions\n", vt.MissingPayees)
 	}
@@ -349,6 +446,9 @@ func (vt *ValidationTracker) PrintSummary() {
 
 	if len(vt.DuplicateTransactions) > 0 {
 		fmt.Printf("  • Potential duplicates: %d groups detected\n", len(vt.DuplicateTransactions))
+		if vt.RemovedDuplicates > 0 {
+			fmt.Printf("    (Removed exact copies: %d)\n", vt.RemovedDuplicates)
+		}
 		for i, dup := range vt.DuplicateTransactions {
 			if i < 5 { // Show first 5
 				fmt.Printf("    - %s | %s | %s (%d times)\n", dup.Date, dup.Payee, dup.Amount, dup.Count)
@@ -359,8 +459,28 @@ func (vt *ValidationTracker) PrintSummary() {
 		}
 	}
 
+	if len(vt.UnmatchedTransfers) > 0 {
+		fmt.Printf("  • Unmatched transfers: %d transfers have no counterpart\n", len(vt.UnmatchedTransfers))
+		for i, tr := range vt.UnmatchedTransfers {
+			if i < 5 { // Show first 5
+				fmt.Printf("    - %s | %s -> %s | %s\n", tr.Date, tr.Account, tr.Counterpart, tr.Amount)
+			}
+		}
+		if len(vt.UnmatchedTransfers) > 5 {
+			fmt.Printf("    ... and %d more\n", len(vt.UnmatchedTransfers)-5)
+		}
+	}
+
+	if len(vt.BalanceMismatches) > 0 {
+		fmt.Printf("  • Balance mismatches: %d accounts don't add up to their stated balance\n", len(vt.BalanceMismatches))
+		for _, b := range vt.BalanceMismatches {
+			fmt.Printf("    - %s: off by %s\n", b.Account, b.Difference)
+		}
+	}
+
 	if len(vt.UnusedMappings) > 0 {
-		for mappingType, values := range vt.UnusedMappings {
+		for _, mappingType := range sortedKeys(vt.UnusedMappings) {
+			values := vt.UnusedMappings[mappingType]
 			fmt.Printf("  • %s mapping: %d rules never used\n", mappingType, len(values))
 			for i, val := range values {
 				if i < 3 {
@@ -375,7 +495,7 @@ func (vt *ValidationTracker) PrintSummary() {
 
 	if len(vt.UnmatchedData) > 0 {
 		unmatchedCount := len(vt.UnmatchedData)
-		fmt.Printf("  • Unmapped values: %d different payees/categories not in mapping files\n", unmatchedCount)
+		fmt.Printf("  • Unmapped values: %d different payees/categories/tags not in mapping files\n", unmatchedCount)
 	}
 
 	fmt.Println("=============================")
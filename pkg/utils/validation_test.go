@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -98,6 +101,94 @@ func TestValidationTrackerAddDuplicate(t *testing.T) {
 	}
 }
 
+func TestValidationTrackerAddAccountDuplicate(t *testing.T) {
+	validator := NewValidationTracker()
+
+	validator.AddAccountDuplicate("Checking", "2025-01-01", "Starbucks", "-5.45", 3, 1)
+	validator.AddRemovedDuplicate()
+	if len(validator.DuplicateTransactions) != 1 {
+		t.Fatalf("Expected 1 duplicate, got %d", len(validator.DuplicateTransactions))
+	}
+	dup := validator.DuplicateTransactions[0]
+	if dup.Account != "Checking" || dup.Count != 3 || dup.Exact != 1 {
+		t.Errorf("Duplicate data not stored correctly: %+v", dup)
+	}
+
+	dir := t.TempDir()
+	if err := validator.WriteValidationLog(dir); err != nil {
+		t.Fatalf("WriteValidationLog failed: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "validation.log"))
+	for _, want := range []string{
+		"(Removed exact copies: 1)",
+		"Account: Checking | Date: 2025-01-01 | Payee: Starbucks | Amount: -5.45 (appears 3 times, exact copies: 1)",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Validation log missing %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestValidationTrackerAddParseProblem(t *testing.T) {
+	validator := NewValidationTracker()
+
+	validator.AddParseProblem(42, "error", "Checking", `transaction skipped: unreadable date "13/45'23"`, "D13/45'23\nT-10.00")
+	if !validator.HasWarnings() {
+		t.Error("Should have warnings after adding a parse problem")
+	}
+
+	dir := t.TempDir()
+	if err := validator.WriteValidationLog(dir); err != nil {
+		t.Fatalf("WriteValidationLog failed: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "validation.log"))
+	for _, want := range []string{
+		"Unreadable records: 1 problems found while reading the QIF file",
+		`- Line 42 [error]: transaction skipped: unreadable date "13/45'23" (Account: Checking)`,
+		"      | D13/45'23\n      | T-10.00\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Validation log missing %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestValidationTrackerAddBalanceMismatch(t *testing.T) {
+	validator := NewValidationTracker()
+
+	validator.AddBalanceMismatch("Savings", "600.00", "575.00", "25.00", "")
+	if !validator.HasWarnings() {
+		t.Error("Should have warnings after adding a balance mismatch")
+	}
+
+	dir := t.TempDir()
+	if err := validator.WriteValidationLog(dir); err != nil {
+		t.Fatalf("WriteValidationLog failed: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "validation.log"))
+	want := "Account: Savings | Stated: 600.00 | Transactions: 575.00 | Difference: 25.00"
+	if !strings.Contains(string(content), want) {
+		t.Errorf("Validation log missing %q:\n%s", want, content)
+	}
+}
+
+func TestValidationTrackerAddUnmatchedTransfer(t *testing.T) {
+	validator := NewValidationTracker()
+
+	validator.AddUnmatchedTransfer("2025-01-10", "Checking", "Savings", "-500.00")
+	if len(validator.UnmatchedTransfers) != 1 {
+		t.Fatalf("Expected 1 unmatched transfer, got %d", len(validator.UnmatchedTransfers))
+	}
+	if !validator.HasWarnings() {
+		t.Error("Should have warnings after adding unmatched transfer")
+	}
+
+	tr := validator.UnmatchedTransfers[0]
+	if tr.Date != "2025-01-10" || tr.Account != "Checking" || tr.Counterpart != "Savings" || tr.Amount != "-500.00" {
+		t.Errorf("Unmatched transfer not stored correctly: %+v", tr)
+	}
+}
+
 func TestValidationTrackerAddUnmatchedData(t *testing.T) {
 	validator := NewValidationTracker()
 